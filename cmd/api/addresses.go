@@ -1,33 +1,39 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
 	"net/http"
+	"sync"
+	"time"
 
 	"github.com/my-eq/go-usps/models"
+	"github.com/pistolricks/ShippingApi/internal/data"
 	uspsApi "github.com/pistolricks/ShippingApi/internal/usps"
+	"github.com/pistolricks/ShippingApi/internal/validator"
 )
 
-func (app *application) handleStandardAddress(w http.ResponseWriter, r *http.Request) {
-
-	var input struct {
-		Firm             string `json:"firm,omitempty"`
-		StreetAddress    string `json:"streetAddress"`
-		SecondaryAddress string `json:"secondaryAddress,omitempty"`
-		City             string `json:"city,omitempty"`
-		State            string `json:"state"`
-		Urbanization     string `json:"urbanization,omitempty"`
-		ZIPCode          string `json:"ZIPCode,omitempty"`
-		ZIPPlus4         string `json:"ZIPPlus4,omitempty"`
-	}
+var (
+	addressCacheHits   = expvar.NewInt("address_cache_hits")
+	addressCacheMisses = expvar.NewInt("address_cache_misses")
+)
 
-	err := app.readJSON(w, r, &input)
-	if err != nil {
-		app.badRequestResponse(w, r, err)
-		return
-	}
+type addressInput struct {
+	Firm             string `json:"firm,omitempty"`
+	StreetAddress    string `json:"streetAddress"`
+	SecondaryAddress string `json:"secondaryAddress,omitempty"`
+	City             string `json:"city,omitempty"`
+	State            string `json:"state"`
+	Urbanization     string `json:"urbanization,omitempty"`
+	ZIPCode          string `json:"ZIPCode,omitempty"`
+	ZIPPlus4         string `json:"ZIPPlus4,omitempty"`
+}
 
-	// Standardize an address
-	req := &models.AddressRequest{
+func (input addressInput) request() *models.AddressRequest {
+	return &models.AddressRequest{
 		Firm:             input.Firm,
 		StreetAddress:    input.StreetAddress,
 		SecondaryAddress: input.SecondaryAddress,
@@ -37,19 +43,253 @@ func (app *application) handleStandardAddress(w http.ResponseWriter, r *http.Req
 		ZIPCode:          input.ZIPCode,
 		ZIPPlus4:         input.ZIPPlus4,
 	}
+}
+
+func (input addressInput) cacheKey() []byte {
+	return data.AddressCacheKey(
+		input.Firm,
+		input.StreetAddress,
+		input.SecondaryAddress,
+		input.City,
+		input.State,
+		input.Urbanization,
+		input.ZIPCode,
+		input.ZIPPlus4,
+	)
+}
+
+func validateAddressInput(v *validator.Validator, input addressInput) {
+	v.Check(input.StreetAddress != "", "streetAddress", "must be provided")
+	v.Check(input.State != "", "state", "must be provided")
+	v.Check(len(input.State) == 2, "state", "must be a 2 letter state code")
+
+	if input.ZIPCode != "" {
+		v.Check(validator.Matches(input.ZIPCode, validator.ZIPCodeRX), "ZIPCode", "must be a 5 digit ZIP code")
+	}
+}
+
+func (app *application) handleStandardAddress(w http.ResponseWriter, r *http.Request) {
+	var input addressInput
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
 
-	resp, err := uspsApi.StandardizedAddress(app.postalClient(), req)
+	v := validator.New()
+
+	if validateAddressInput(v, input); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	resp, err := app.standardizeAddress(r.Context(), input)
+	if err != nil {
+		app.uspsErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"address": uspsApi.NewAddressResult(input.request(), resp)}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
+}
+
+// standardizeAddress returns the USPS standardized form of input, answering
+// from the address cache when possible. Cache errors are logged and never
+// fail the lookup.
+func (app *application) standardizeAddress(ctx context.Context, input addressInput) (*models.AddressResponse, error) {
+	if app.config.addresses.cacheTTL <= 0 {
+		return uspsApi.StandardizedAddress(ctx, app.addressClient, input.request())
+	}
+
+	key := input.cacheKey()
+
+	cached, err := app.models.AddressCache.Get(key)
+	switch {
+	case err == nil:
+		var resp models.AddressResponse
 
-	err = app.writeJSON(w, http.StatusOK, envelope{
-		"Street":  resp.Address.StreetAddress,
-		"City":    resp.Address.City,
-		"State":   resp.Address.State,
-		"ZIPCode": resp.Address.ZIPCode,
-	}, nil)
+		err = json.Unmarshal(cached, &resp)
+		if err == nil {
+			addressCacheHits.Add(1)
+			return &resp, nil
+		}
+
+		app.logger.Error(err.Error())
+	case !errors.Is(err, data.ErrRecordNotFound):
+		app.logger.Error(err.Error())
+	}
+
+	addressCacheMisses.Add(1)
+
+	resp, err := uspsApi.StandardizedAddress(ctx, app.addressClient, input.request())
+	if err != nil {
+		return nil, err
+	}
+
+	js, err := json.Marshal(resp)
+	if err == nil {
+		err = app.models.AddressCache.Set(key, js, app.config.addresses.cacheTTL)
+	}
+	if err != nil {
+		app.logger.Error(err.Error())
+	}
+
+	return resp, nil
+}
+
+// purgeAddressCache deletes expired address cache entries. It runs hourly.
+func (app *application) purgeAddressCache(ctx context.Context) {
+	n, err := app.models.AddressCache.DeleteExpired(ctx)
+	if err != nil {
+		app.logger.Error(err.Error())
+		return
+	}
+
+	if n > 0 {
+		app.logger.Info("purged expired address cache entries", "count", n)
+	}
+}
+
+type addressBatchResult struct {
+	Index   int                    `json:"index"`
+	Address *uspsApi.AddressResult `json:"address,omitempty"`
+	Error   string                 `json:"error,omitempty"`
+	Errors  map[string]string      `json:"errors,omitempty"`
+}
+
+// addressBatchError returns the message reported for a batch address that
+// failed to standardize. USPS errors can echo the request or carry OAuth
+// details, so only a fixed message is reported and the error is logged.
+func addressBatchError(err error) string {
+	switch {
+	case errors.Is(err, uspsApi.ErrAddressNotFound):
+		return "the address could not be found"
+	case errors.Is(err, uspsApi.ErrAmbiguousAddress):
+		return "the address matches more than one address, please add more detail"
+	case errors.Is(err, uspsApi.ErrInvalidZIPCode):
+		return "the ZIP code is not valid"
+	case errors.Is(err, uspsApi.ErrInvalidAddress):
+		return "the address is not a deliverable address"
+	case errors.Is(err, uspsApi.ErrAuthFailed), errors.Is(err, uspsApi.ErrUpstreamUnavailable), errors.Is(err, context.DeadlineExceeded):
+		return "the address service is unavailable, please try again later"
+	default:
+		return "the address could not be standardized"
+	}
+}
+
+func (app *application) handleStandardAddressBatch(w http.ResponseWriter, r *http.Request) {
+	inputs, err := app.readAddressBatch(w, r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	v.Check(len(inputs) > 0, "addresses", "must contain at least 1 address")
+	v.Check(len(inputs) <= app.config.addresses.batchMaxSize, "addresses", fmt.Sprintf("must not contain more than %d addresses", app.config.addresses.batchMaxSize))
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// Large batches can run well past the server's write timeout, so extend
+	// the deadline for this response only.
+	rc := http.NewResponseController(w)
+	_ = rc.SetWriteDeadline(time.Now().Add(app.config.addresses.batchTimeout + 5*time.Second))
+
+	ctx, cancel := context.WithTimeout(r.Context(), app.config.addresses.batchTimeout)
+	defer cancel()
+
+	var (
+		results = make([]addressBatchResult, len(inputs))
+		sem     = make(chan struct{}, max(app.config.addresses.batchConcurrency, 1))
+		wg      sync.WaitGroup
+	)
+
+	for i, input := range inputs {
+		results[i].Index = i
+
+		v := validator.New()
+
+		if validateAddressInput(v, input); !v.Valid() {
+			results[i].Errors = v.Errors
+			continue
+		}
+
+		sem <- struct{}{}
+
+		wg.Go(func() {
+			defer func() { <-sem }()
+
+			resp, err := app.standardizeAddress(ctx, input)
+			if err != nil {
+				app.logger.Error(err.Error(), "method", r.Method, "uri", r.URL.RequestURI(), "index", i)
+				results[i].Error = addressBatchError(err)
+				return
+			}
+
+			results[i].Address = uspsApi.NewAddressResult(input.request(), resp)
+		})
+	}
+
+	wg.Wait()
+
+	failed := 0
+	for _, result := range results {
+		if result.Address == nil {
+			failed++
+		}
+	}
+
+	env := envelope{
+		"results": results,
+		"summary": map[string]int{
+			"total":     len(results),
+			"succeeded": len(results) - failed,
+			"failed":    failed,
+		},
+	}
+
+	err = app.writeJSON(w, http.StatusOK, env, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 }
+
+// readAddressBatch reads a batch of addresses from either a JSON array body,
+// a text/csv body or a CSV file uploaded in the "file" field of a multipart
+// form.
+func (app *application) readAddressBatch(w http.ResponseWriter, r *http.Request) ([]addressInput, error) {
+	body, ok, err := app.openCSVUpload(w, r)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		var inputs []addressInput
+
+		err := app.readJSON(w, r, &inputs)
+		return inputs, err
+	}
+	defer body.Close()
+
+	return parseCSV(body, addressCSVColumns)
+}
+
+// addressCSVColumns sets the address fields from CSV columns. The column
+// names match the JSON keys.
+var addressCSVColumns = map[string]func(*addressInput, string) error{
+	"firm":             func(a *addressInput, s string) error { a.Firm = s; return nil },
+	"streetaddress":    func(a *addressInput, s string) error { a.StreetAddress = s; return nil },
+	"secondaryaddress": func(a *addressInput, s string) error { a.SecondaryAddress = s; return nil },
+	"city":             func(a *addressInput, s string) error { a.City = s; return nil },
+	"state":            func(a *addressInput, s string) error { a.State = s; return nil },
+	"urbanization":     func(a *addressInput, s string) error { a.Urbanization = s; return nil },
+	"zipcode":          func(a *addressInput, s string) error { a.ZIPCode = s; return nil },
+	"zipplus4":         func(a *addressInput, s string) error { a.ZIPPlus4 = s; return nil },
+}
@@ -24,3 +24,19 @@ func (app *application) contextGetUser(r *http.Request) *data.User {
 
 	return user
 }
+
+const storeContextKey = contextKey("store")
+
+func (app *application) contextSetStore(r *http.Request, store *data.Store) *http.Request {
+	ctx := context.WithValue(r.Context(), storeContextKey, store)
+	return r.WithContext(ctx)
+}
+
+func (app *application) contextGetStore(r *http.Request) *data.Store {
+	store, ok := r.Context().Value(storeContextKey).(*data.Store)
+	if !ok {
+		panic("missing store value in request context")
+	}
+
+	return store
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/pistolricks/ShippingApi/internal/customs"
+	"github.com/pistolricks/ShippingApi/internal/data"
+	uspsApi "github.com/pistolricks/ShippingApi/internal/usps"
+	"github.com/pistolricks/ShippingApi/internal/validator"
+)
+
+// handleUpdateShipmentCustoms builds the customs declaration of an
+// international shipment from its order line items and stores it with the
+// shipment. Line items that name a catalog SKU take their description and
+// unit weight from the product when they are left out.
+func (app *application) handleUpdateShipmentCustoms(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	shipment, err := app.models.Shipments.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		ContentsType        string             `json:"contents_type"`
+		ContentsExplanation string             `json:"contents_explanation"`
+		NonDeliveryOption   string             `json:"non_delivery_option"`
+		CertifySigner       string             `json:"certify_signer"`
+		Incoterm            string             `json:"incoterm"`
+		EELPFC              string             `json:"eel_pfc"`
+		AESITN              string             `json:"aes_itn"`
+		Currency            string             `json:"currency"`
+		Items               []customs.LineItem `json:"items"`
+		Version             *int               `json:"version"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Version != nil && *input.Version != shipment.Version {
+		app.editConflictResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+
+	v.Check(shipment.Status == data.ShipmentStatusDraft || shipment.Status == data.ShipmentStatusRated, "status", "customs can only be changed before a label is purchased")
+	v.Check(uspsApi.IsInternational(shipment.AddressTo.Country), "address_to.country", "customs declarations are only needed for international shipments")
+	v.Check(len(input.Items) <= customs.MaxItems, "items", fmt.Sprintf("must not contain more than %d items", customs.MaxItems))
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.fillCustomsLineItems(v, input.Items)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	declaration := customs.Build(customs.Declaration{
+		ContentsType:        input.ContentsType,
+		ContentsExplanation: input.ContentsExplanation,
+		NonDeliveryOption:   input.NonDeliveryOption,
+		CertifySigner:       input.CertifySigner,
+		Incoterm:            input.Incoterm,
+		EELPFC:              input.EELPFC,
+		AESITN:              input.AESITN,
+		Currency:            input.Currency,
+	}, input.Items)
+
+	var parcelWeight float64
+	for _, parcel := range shipment.Parcels {
+		parcelWeight += parcel.Weight
+	}
+
+	v.Check(declaration.TotalWeight <= parcelWeight, "items", "net weight must not be more than the weight of the parcels")
+
+	if customs.ValidateDeclaration(v, declaration); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// The Shippo declaration and rates were made with the old customs, so a
+	// rated shipment goes back to draft and must be rated again, which
+	// creates a new declaration.
+	shipment.Customs = declaration
+	shipment.ShippoCustomsID = ""
+
+	if shipment.Status == data.ShipmentStatusRated {
+		shipment.Transition(data.ShipmentStatusDraft)
+		shipment.ShippoShipmentID = ""
+	}
+
+	err = app.models.Shipments.Update(shipment)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"shipment": shipment}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// fillCustomsLineItems completes line items from the product catalog. A SKU
+// missing from the catalog is only an error when the line needs the product
+// to fill in its description or weight.
+func (app *application) fillCustomsLineItems(v *validator.Validator, items []customs.LineItem) error {
+	var skus []string
+	for _, item := range items {
+		if item.SKU != "" && (item.Description == "" || item.UnitWeight == 0) {
+			skus = append(skus, item.SKU)
+		}
+	}
+
+	if len(skus) == 0 {
+		return nil
+	}
+
+	products, err := app.models.Products.GetForSKUs(skus)
+	if err != nil {
+		return err
+	}
+
+	for i := range items {
+		item := &items[i]
+		if item.SKU == "" || (item.Description != "" && item.UnitWeight != 0) {
+			continue
+		}
+
+		product, ok := products[item.SKU]
+		if !ok {
+			v.AddError(fmt.Sprintf("items[%d].sku", i), "no matching product found")
+			continue
+		}
+
+		if item.Description == "" {
+			item.Description = product.Name
+		}
+		if item.UnitWeight == 0 {
+			item.UnitWeight = product.Weight
+		}
+	}
+
+	return nil
+}
@@ -1,8 +1,11 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+
+	uspsApi "github.com/pistolricks/ShippingApi/internal/usps"
 )
 
 func (app *application) logError(r *http.Request, err error) {
@@ -71,6 +74,16 @@ func (app *application) invalidAuthenticationTokenResponse(w http.ResponseWriter
 	app.errorResponse(w, r, http.StatusUnauthorized, message)
 }
 
+func (app *application) invalidSignatureResponse(w http.ResponseWriter, r *http.Request) {
+	message := "invalid or missing request signature"
+	app.errorResponse(w, r, http.StatusUnauthorized, message)
+}
+
+func (app *application) invalidStoreKeyResponse(w http.ResponseWriter, r *http.Request) {
+	message := "invalid or missing store API key"
+	app.errorResponse(w, r, http.StatusUnauthorized, message)
+}
+
 func (app *application) authenticationRequiredResponse(w http.ResponseWriter, r *http.Request) {
 	message := "you must be authenticated to access this resource"
 	app.errorResponse(w, r, http.StatusUnauthorized, message)
@@ -85,3 +98,57 @@ func (app *application) notPermittedResponse(w http.ResponseWriter, r *http.Requ
 	message := "your user account doesn't have the necessary permissions to access this resource"
 	app.errorResponse(w, r, http.StatusForbidden, message)
 }
+
+func (app *application) badGatewayResponse(w http.ResponseWriter, r *http.Request, err error) {
+	app.logError(r, err)
+
+	message := "an upstream service rejected the request, please try again later"
+	app.errorResponse(w, r, http.StatusBadGateway, message)
+}
+
+func (app *application) serviceUnavailableResponse(w http.ResponseWriter, r *http.Request, err error) {
+	app.logError(r, err)
+
+	message := "an upstream service is temporarily unavailable, please try again later"
+	app.errorResponse(w, r, http.StatusServiceUnavailable, message)
+}
+
+func (app *application) uspsErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, uspsApi.ErrAddressNotFound):
+		app.errorResponse(w, r, http.StatusNotFound, "the address could not be found")
+	case errors.Is(err, uspsApi.ErrAmbiguousAddress):
+		app.failedValidationResponse(w, r, map[string]string{"streetAddress": "matches more than one address, please add more detail"})
+	case errors.Is(err, uspsApi.ErrInvalidZIPCode):
+		app.failedValidationResponse(w, r, map[string]string{"ZIPCode": "is not a valid ZIP code"})
+	case errors.Is(err, uspsApi.ErrInvalidAddress):
+		// The USPS message can echo the request back, so it is only logged.
+		app.logError(r, err)
+		app.failedValidationResponse(w, r, map[string]string{"address": "is not a deliverable address"})
+	case errors.Is(err, uspsApi.ErrAuthFailed):
+		app.badGatewayResponse(w, r, err)
+	case errors.Is(err, uspsApi.ErrUpstreamUnavailable):
+		app.serviceUnavailableResponse(w, r, err)
+	default:
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// pricesErrorResponse answers a failed USPS prices call: 502 or 503 when USPS
+// itself failed, and 400 with a fixed message when it rejected what the
+// client sent.
+func (app *application) pricesErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, uspsApi.ErrUpstreamUnavailable):
+		app.serviceUnavailableResponse(w, r, err)
+	case errors.Is(err, uspsApi.ErrAuthFailed):
+		app.badGatewayResponse(w, r, err)
+	case errors.Is(err, uspsApi.ErrRequestRejected):
+		// The USPS error carries the upstream response body, so it is only
+		// logged.
+		app.logError(r, err)
+		app.errorResponse(w, r, http.StatusBadRequest, "USPS could not price this request, please check the mail class, weight and dimensions")
+	default:
+		app.serverErrorResponse(w, r, err)
+	}
+}
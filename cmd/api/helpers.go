@@ -1,22 +1,22 @@
 package main
 
 import (
+	"context"
+	"encoding/csv"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
-	"github.com/coldbrewcloud/go-shippo"
-	"github.com/my-eq/go-usps"
-	uspsApi "github.com/pistolricks/ShippingApi/internal/usps"
+	"github.com/pistolricks/ShippingApi/internal/shippo"
 	"github.com/pistolricks/ShippingApi/internal/validator"
 
-	"github.com/coldbrewcloud/go-shippo/client"
-
 	"github.com/julienschmidt/httprouter"
 )
 
@@ -155,19 +155,110 @@ func (app *application) background(fn func()) {
 	})
 }
 
-func (app *application) postalClient() *usps.Client {
-	return usps.NewClientWithOAuth(app.config.usps.key, app.config.usps.secret)
+// every runs fn in the background once per interval until ctx is canceled.
+// A run that panics is logged and the next one still happens.
+func (app *application) every(ctx context.Context, interval time.Duration, fn func(ctx context.Context)) {
+	app.wg.Go(func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				func() {
+					defer func() {
+						pv := recover()
+						if pv != nil {
+							app.logger.Error(fmt.Sprintf("%v", pv))
+						}
+					}()
+
+					fn(ctx)
+				}()
+			}
+		}
+	})
+}
+
+func (app *application) shippoClient() (*shippo.Client, error) {
+	return shippo.New(app.config.shippo.key)
 }
-func (app *application) postalRatesClient() *uspsApi.PricesClient {
-	return uspsApi.NewPricesClient(app.config.usps.key, app.config.usps.secret)
+
+// openCSVUpload returns the CSV sent either as a text/csv body or as the
+// "file" field of a multipart form, limited to 10MB. ok is false when the
+// request has some other content type, leaving the body unread.
+func (app *application) openCSVUpload(w http.ResponseWriter, r *http.Request) (body io.ReadCloser, ok bool, err error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	switch mediaType {
+	case "text/csv":
+		r.Body = http.MaxBytesReader(w, r.Body, 10<<20)
+		return r.Body, true, nil
+
+	case "multipart/form-data":
+		r.Body = http.MaxBytesReader(w, r.Body, 10<<20)
+
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			return nil, true, errors.New("body must contain a CSV file in the \"file\" field")
+		}
+
+		return file, true, nil
+
+	default:
+		return nil, false, nil
+	}
 }
 
-func (app *application) shippoClient() *client.Client {
-	privateToken := app.config.shippo.key
-	if privateToken == "" {
-		panic(errors.New("Please set $PRIVATE_TOKEN with your Shippo API private token."))
+// parseCSV parses CSV with a header row into one T per record. Each header
+// names a column in setters, case-insensitively, and the setter stores the
+// trimmed value of that column in the record.
+func parseCSV[T any](r io.Reader, setters map[string]func(*T, string) error) ([]T, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("CSV must not be empty")
+		}
+		return nil, fmt.Errorf("badly-formed CSV: %w", err)
+	}
+
+	columns := make([]func(*T, string) error, len(header))
+
+	for i, name := range header {
+		setter, ok := setters[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return nil, fmt.Errorf("CSV contains unknown column %q", name)
+		}
+		columns[i] = setter
+	}
+
+	var records []T
+
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("badly-formed CSV: %w", err)
+		}
+
+		var out T
+		for i, value := range record {
+			err := columns[i](&out, strings.TrimSpace(value))
+			if err != nil {
+				line, _ := reader.FieldPos(i)
+				return nil, fmt.Errorf("CSV line %d column %q: %w", line, header[i], err)
+			}
+		}
+
+		records = append(records, out)
 	}
 
-	// create a Shippo Client instance
-	return shippo.NewClient(privateToken)
+	return records, nil
 }
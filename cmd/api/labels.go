@@ -0,0 +1,304 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/pistolricks/ShippingApi/internal/data"
+	"github.com/pistolricks/ShippingApi/internal/shippo"
+	uspsApi "github.com/pistolricks/ShippingApi/internal/usps"
+	"github.com/pistolricks/ShippingApi/internal/validator"
+)
+
+func (app *application) handlePurchaseLabel(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		ShipmentID int64  `json:"shipment_id"`
+		RateID     string `json:"rate_id"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	v.Check(input.ShipmentID > 0, "shipment_id", "must be provided")
+	v.Check(input.RateID != "", "rate_id", "must be provided")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	shipment, err := app.models.Shipments.Get(input.ShipmentID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("shipment_id", "no matching shipment found")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	v.Check(shipment.CanTransition(data.ShipmentStatusPurchasing), "shipment_id", "shipment must be rated before a label is purchased")
+	v.Check(shipment.Customs != nil || !uspsApi.IsInternational(shipment.AddressTo.Country), "shipment_id", "international shipments need a customs declaration before a label is purchased")
+	v.Check(shipment.Customs == nil || shipment.ShippoCustomsID != "", "shipment_id", "shipment must be rated again with its customs declaration before a label is purchased")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	client, err := app.shippoClient()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	_, err = client.ShipmentRate(shipment.ShippoShipmentID, input.RateID)
+	if err != nil {
+		switch {
+		case errors.Is(err, shippo.ErrRateNotInShipment):
+			v.AddError("rate_id", "must be one of the rates quoted for this shipment")
+			app.failedValidationResponse(w, r, v.Errors)
+		case shippo.IsRejected(err):
+			app.badRequestResponse(w, r, err)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// Claim the shipment before paying for anything, so that a concurrent
+	// request for the same shipment gets an edit conflict instead of buying
+	// a second label.
+	shipment.Transition(data.ShipmentStatusPurchasing)
+
+	err = app.models.Shipments.Update(shipment)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// International shipments are rated with their customs declaration, so
+	// the confirmed rate already carries it.
+	transaction, err := client.PurchaseLabel(input.RateID)
+	if err != nil {
+		app.releasePurchase(shipment)
+
+		switch {
+		case shippo.IsRejected(err):
+			app.badRequestResponse(w, r, err)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// The label is paid for at this point. Record its transaction first, so
+	// that it can be reconciled if saving the label fails.
+	err = app.models.Shipments.RecordTransaction(shipment, transaction.ID)
+	if err != nil {
+		app.logger.Error("purchased label transaction was not recorded", "transaction_id", transaction.ID, "shipment_id", shipment.ID, "error", err.Error())
+	}
+
+	label := newLabel(transaction)
+
+	err = app.models.Shipments.AttachLabel(shipment, label)
+	if err != nil {
+		// The shipment stays purchasing with the transaction recorded, for
+		// handleReconcileShipmentLabel to save the label later.
+		app.logger.Error("purchased label was not saved", "transaction_id", transaction.ID, "shipment_id", shipment.ID, "error", err.Error())
+
+		switch {
+		case errors.Is(err, data.ErrDuplicateLabel):
+			v.AddError("rate_id", "a label has already been purchased for this rate")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.notifyCustomer(shipment, data.NotificationLabelCreated)
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/api/v1/labels/%d", label.ID))
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"label": label, "shipment": shipment}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) handleShowLabel(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	label, err := app.models.Labels.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"label": label}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// purchaseTimeout is how long a shipment may be purchasing before it is
+// taken to be stuck, well past the time Shippo takes to buy a label.
+const purchaseTimeout = 5 * time.Minute
+
+// handleReconcileShipmentLabel recovers a shipment left purchasing by a
+// label purchase that never finished. When the purchase recorded a Shippo
+// transaction, the label is fetched from Shippo and saved; otherwise the
+// purchase is taken to have failed and the shipment goes back to rated.
+// Shipments that shouldn't be rated again can be voided instead.
+func (app *application) handleReconcileShipmentLabel(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	shipment, err := app.models.Shipments.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	v := validator.New()
+
+	if v.Check(shipment.Status == data.ShipmentStatusPurchasing, "status", "only shipments purchasing a label can be reconciled"); v.Valid() {
+		v.Check(time.Since(shipment.UpdatedAt) >= purchaseTimeout, "status", "a label purchase is still in progress, please try again later")
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if shipment.ShippoTransactionID == "" {
+		err = shipment.Transition(data.ShipmentStatusRated)
+		if err == nil {
+			err = app.models.Shipments.Update(shipment)
+		}
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrEditConflict):
+				app.editConflictResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		err = app.writeJSON(w, http.StatusOK, envelope{"shipment": shipment}, nil)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	client, err := app.shippoClient()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	transaction, err := client.Transaction(shipment.ShippoTransactionID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	label := newLabel(transaction)
+
+	err = app.models.Shipments.AttachLabel(shipment, label)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrInvalidTransition):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.notifyCustomer(shipment, data.NotificationLabelCreated)
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"label": label, "shipment": shipment}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// newLabel returns the label of a purchased Shippo transaction.
+func newLabel(transaction *shippo.Transaction) *data.Label {
+	return &data.Label{
+		TransactionID:  transaction.ID,
+		RateID:         transaction.RateID,
+		Carrier:        transaction.Provider,
+		ServiceLevel:   transaction.ServiceLevel,
+		TrackingNumber: transaction.TrackingNumber,
+		TrackingURL:    transaction.TrackingURL,
+		LabelURL:       transaction.LabelURL,
+		Cost:           transaction.Cost,
+		Currency:       transaction.Currency,
+	}
+}
+
+// releasePurchase moves a shipment whose label purchase failed back to
+// rated, so the purchase can be tried again.
+func (app *application) releasePurchase(shipment *data.Shipment) {
+	err := shipment.Transition(data.ShipmentStatusRated)
+	if err == nil {
+		err = app.models.Shipments.Update(shipment)
+	}
+	if err != nil {
+		app.logger.Error(err.Error(), "shipment_id", shipment.ID)
+	}
+}
+
+// createShippoCustoms creates the Shippo customs declaration of a shipment
+// with customs that doesn't have one yet. The ID is saved with the shipment
+// by the caller's next update, so rating the shipment again reuses it.
+func (app *application) createShippoCustoms(client *shippo.Client, shipment *data.Shipment) error {
+	if shipment.Customs == nil || shipment.ShippoCustomsID != "" {
+		return nil
+	}
+
+	id, err := client.CreateCustomsDeclaration(shippoCustoms(shipment.Customs))
+	if err != nil {
+		return err
+	}
+
+	shipment.ShippoCustomsID = id
+	return nil
+}
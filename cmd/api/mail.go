@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/pistolricks/ShippingApi/internal/data"
+	"github.com/pistolricks/ShippingApi/internal/mailer"
+	"github.com/pistolricks/ShippingApi/internal/validator"
+)
+
+// mailOutbox renders emails and queues them in the outbox, from where
+// deliverMail sends them. Queuing is a database write, so handlers can do it
+// inline and a failed SMTP server can't lose the email.
+type mailOutbox struct {
+	model data.MailOutboxModel
+}
+
+func (o mailOutbox) Send(recipient string, templateFile string, input any) error {
+	return queueMail(o.model, recipient, templateFile, input)
+}
+
+// queueMail renders an email and inserts it into the outbox through model,
+// which may be bound to a transaction so that the email is only queued when
+// the rest of the transaction commits.
+func queueMail(model data.MailOutboxModel, recipient string, templateFile string, input any) error {
+	message, err := mailer.Render(recipient, templateFile, input)
+	if err != nil {
+		return err
+	}
+
+	return model.Insert(&data.MailMessage{
+		Recipient: message.Recipient,
+		Template:  templateFile,
+		Subject:   message.Subject,
+		PlainBody: message.PlainBody,
+		HTMLBody:  message.HTMLBody,
+	})
+}
+
+// mailBackoff is how long to wait before the next attempt after the given
+// number of failed ones: 30s, 1m, 2m, ... capped at 6h.
+func mailBackoff(attempts int) time.Duration {
+	const (
+		base    = 30 * time.Second
+		ceiling = 6 * time.Hour
+	)
+
+	backoff := base
+	for i := 1; i < attempts && backoff < ceiling; i++ {
+		backoff *= 2
+	}
+
+	return min(backoff, ceiling)
+}
+
+// deliverMail sends the outbox messages that are due, a batch at a time. A
+// message that fails -mail-max-attempts times is moved to the dead status,
+// where an admin can see it and resend it.
+func (app *application) deliverMail(ctx context.Context) {
+	batchSize, maxAttempts := app.config.mail.batchSize, app.config.mail.maxAttempts
+
+	messages, err := app.models.Outbox.ClaimDue(batchSize)
+	if err != nil {
+		app.logger.Error(err.Error())
+		return
+	}
+
+	for _, message := range messages {
+		// Messages left unsent here are picked up again once their lease
+		// runs out.
+		if ctx.Err() != nil {
+			return
+		}
+
+		err := app.mailer.Deliver(&mailer.Message{
+			Recipient: message.Recipient,
+			Subject:   message.Subject,
+			PlainBody: message.PlainBody,
+			HTMLBody:  message.HTMLBody,
+		})
+		if err == nil {
+			err = app.models.Outbox.MarkSent(message)
+			if err != nil {
+				app.logger.Error(err.Error(), "mail_id", message.ID)
+			}
+			continue
+		}
+
+		var retryIn time.Duration
+		if message.Attempts < maxAttempts {
+			retryIn = mailBackoff(message.Attempts)
+		}
+
+		markErr := app.models.Outbox.MarkFailed(message, err, retryIn)
+		if markErr != nil {
+			app.logger.Error(markErr.Error(), "mail_id", message.ID)
+			continue
+		}
+
+		app.logger.Error(err.Error(), "mail_id", message.ID, "attempts", message.Attempts, "status", message.Status)
+	}
+}
+
+// purgeMail deletes sent and dead messages older than -mail-retention.
+func (app *application) purgeMail(ctx context.Context) {
+	n, err := app.models.Outbox.DeleteOlderThan(app.config.mail.retention)
+	if err != nil {
+		app.logger.Error(err.Error())
+		return
+	}
+
+	if n > 0 {
+		app.logger.Info("purged old outbox messages", "count", n)
+	}
+}
+
+func (app *application) handleListMail(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Status string
+		data.Filters
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	input.Status = app.readString(qs, "status", "")
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+
+	input.Filters.Sort = app.readString(qs, "sort", "-created_at")
+	input.Filters.SortSafelist = []string{"id", "created_at", "updated_at", "attempts", "-id", "-created_at", "-updated_at", "-attempts"}
+
+	if input.Status != "" {
+		v.Check(validator.PermittedValue(input.Status, data.MailStatuses...), "status", "invalid status")
+	}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	messages, metadata, err := app.models.Outbox.GetAll(input.Status, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"mail": messages, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) handleResendMail(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	message, err := app.models.Outbox.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	v := validator.New()
+
+	v.Check(message.Status == data.MailStatusDead, "status", "only failed messages can be resent")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Outbox.Requeue(message)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusAccepted, envelope{"mail": message}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
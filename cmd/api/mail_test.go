@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pistolricks/ShippingApi/internal/data"
+	"github.com/pistolricks/ShippingApi/internal/mailer"
+)
+
+func TestMailBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{attempts: 0, want: 30 * time.Second},
+		{attempts: 1, want: 30 * time.Second},
+		{attempts: 2, want: time.Minute},
+		{attempts: 3, want: 2 * time.Minute},
+		{attempts: 10, want: 4*time.Hour + 16*time.Minute},
+		{attempts: 11, want: 6 * time.Hour},
+		{attempts: 100, want: 6 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		got := mailBackoff(tt.attempts)
+
+		if got != tt.want {
+			t.Errorf("mailBackoff(%d) = %v; want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+// TestDeliverMail queues an email in the outbox of the migrated database in
+// GREENLIGHT_TEST_DB_DSN and delivers it to a MemorySender.
+func TestDeliverMail(t *testing.T) {
+	dsn := os.Getenv("GREENLIGHT_TEST_DB_DSN")
+	if dsn == "" {
+		t.Skip("GREENLIGHT_TEST_DB_DSN is not set")
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	sender := mailer.NewMemorySender(nil)
+
+	app := &application{
+		logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		models: data.NewModels(db),
+		mailer: mailer.New(sender),
+	}
+	app.config.mail.batchSize = 1000
+	app.config.mail.maxAttempts = 3
+
+	recipient := "deliver-mail-test@example.com"
+
+	t.Cleanup(func() { db.Exec(`DELETE FROM mail_outbox WHERE recipient = $1`, recipient) })
+
+	err = queueMail(app.models.Outbox, recipient, "token_activation.tmpl", map[string]any{"activationToken": "ACTIVATIONTOKEN"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app.deliverMail(context.Background())
+
+	var delivered []mailer.Message
+	for _, message := range sender.Messages() {
+		if message.Recipient == recipient {
+			delivered = append(delivered, message)
+		}
+	}
+
+	if len(delivered) != 1 {
+		t.Fatalf("got %d messages to %s; want 1", len(delivered), recipient)
+	}
+	if !strings.Contains(delivered[0].PlainBody, "ACTIVATIONTOKEN") {
+		t.Errorf("delivered body does not contain the token:\n%s", delivered[0].PlainBody)
+	}
+
+	var status, body string
+	err = db.QueryRow(`SELECT status, plain_body FROM mail_outbox WHERE recipient = $1`, recipient).Scan(&status, &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if status != data.MailStatusSent || body != "" {
+		t.Errorf("got status %q with body %q; want sent with the body cleared", status, body)
+	}
+}
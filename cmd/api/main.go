@@ -3,20 +3,28 @@ package main
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"expvar"
 	"flag"
 	"fmt"
 	"log/slog"
 	"os"
 	"runtime"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/coldbrewcloud/go-shippo/models"
 	_ "github.com/lib/pq"
+	"github.com/my-eq/go-usps"
 	"github.com/pistolricks/ShippingApi/internal/data"
+	"github.com/pistolricks/ShippingApi/internal/delivery"
+	"github.com/pistolricks/ShippingApi/internal/duties"
 	"github.com/pistolricks/ShippingApi/internal/mailer"
+	"github.com/pistolricks/ShippingApi/internal/notify"
+	"github.com/pistolricks/ShippingApi/internal/packing"
+	uspsApi "github.com/pistolricks/ShippingApi/internal/usps"
 	"github.com/pistolricks/ShippingApi/internal/vcs"
 )
 
@@ -34,18 +42,63 @@ type config struct {
 		maxIdleTime  time.Duration
 	}
 	usps struct {
-		key    string
+		key           string
+		secret        string
+		accountType   string
+		accountNumber string
+		tokenRefresh  time.Duration
+		baseURL       string
+		cubicPricing  bool
+	}
+	addresses struct {
+		batchMaxSize     int
+		batchConcurrency int
+		batchTimeout     time.Duration
+		cacheTTL         time.Duration
+	}
+	rates struct {
+		parcel struct {
+			length float64
+			width  float64
+			height float64
+		}
+	}
+	packing struct {
+		boxes []packing.Box
+	}
+	duties struct {
+		table duties.Table
+	}
+	tracking struct {
+		interval  time.Duration
+		batchSize int
+	}
+	delivery struct {
+		cutoff   time.Duration
+		location *time.Location
+		holidays *delivery.Calendar
+	}
+	shopify struct {
 		secret string
 	}
 	shippo struct {
-		key     string
-		address *models.AddressInput
+		key           string
+		webhookSecret string
+		address       *models.AddressInput
 	}
 	limiter struct {
 		enabled bool
 		rps     float64
 		burst   int
 	}
+	mail struct {
+		transport   string
+		dir         string
+		interval    time.Duration
+		batchSize   int
+		maxAttempts int
+		retention   time.Duration
+	}
 	smtp struct {
 		host     string
 		port     int
@@ -60,11 +113,16 @@ type config struct {
 }
 
 type application struct {
-	config config
-	logger *slog.Logger
-	models data.Models
-	mailer *mailer.Mailer
-	wg     sync.WaitGroup
+	config         config
+	logger         *slog.Logger
+	models         data.Models
+	mailer         *mailer.Mailer
+	notifier       *notify.Service
+	addressClient  *usps.Client
+	pricesClient   *uspsApi.PricesClient
+	trackingClient *uspsApi.TrackingClient
+	estimator      *delivery.Estimator
+	wg             sync.WaitGroup
 }
 
 func main() {
@@ -83,15 +141,76 @@ func main() {
 	flag.Float64Var(&cfg.limiter.rps, "limiter-rps", 2, "Rate limiter maximum requests per second")
 	flag.IntVar(&cfg.limiter.burst, "limiter-burst", 4, "Rate limiter maximum burst")
 
-	flag.StringVar(&cfg.smtp.host, "smtp-host", "sandbox.smtp.mailtrap.io", "SMTP host")
+	flag.StringVar(&cfg.mail.transport, "mail-transport", "smtp", "How emails are delivered (smtp|dir|memory)")
+	flag.StringVar(&cfg.mail.dir, "mail-dir", "tmp/mail", "Directory .eml files are written to with -mail-transport=dir")
+
+	flag.StringVar(&cfg.smtp.host, "smtp-host", "", "SMTP host")
 	flag.IntVar(&cfg.smtp.port, "smtp-port", 25, "SMTP port")
-	flag.StringVar(&cfg.smtp.username, "smtp-username", "a7420fc0883489", "SMTP username")
-	flag.StringVar(&cfg.smtp.password, "smtp-password", "e75ffd0a3aa5ec", "SMTP password")
+	flag.StringVar(&cfg.smtp.username, "smtp-username", "", "SMTP username")
+	flag.StringVar(&cfg.smtp.password, "smtp-password", "", "SMTP password")
 	flag.StringVar(&cfg.smtp.sender, "smtp-sender", "Greenlight <no-reply@github.com/pistolricks/ShippingApi>", "SMTP sender")
 
 	flag.StringVar(&cfg.usps.key, "consumer-key", "", "Consumer Key")
 	flag.StringVar(&cfg.usps.secret, "consumer-secret", "", "Consumer Secret")
+	flag.StringVar(&cfg.usps.accountType, "usps-account-type", "EPS", "USPS account type (EPS|PERMIT|METER)")
+	flag.StringVar(&cfg.usps.accountNumber, "usps-account-number", "", "USPS account number")
+	flag.DurationVar(&cfg.usps.tokenRefresh, "usps-token-refresh", uspsApi.DefaultTokenRefreshWindow, "Refresh the USPS OAuth token this long before it expires")
+	flag.BoolVar(&cfg.usps.cubicPricing, "usps-cubic-pricing", false, "Quote cubic prices for eligible parcels (requires a cubic-eligible USPS account)")
+	flag.StringVar(&cfg.usps.baseURL, "usps-base-url", "", "Send USPS requests to this host instead of apis.usps.com (e.g. a uspsfake server)")
 	flag.StringVar(&cfg.shippo.key, "shippo-key", "", "Shippo Key")
+	flag.StringVar(&cfg.shippo.webhookSecret, "shippo-webhook-secret", "", "Shippo webhook secret used to verify tracking and transaction webhooks")
+
+	cfg.rates.parcel.length, cfg.rates.parcel.width, cfg.rates.parcel.height = 12, 9, 6
+
+	flag.Func("default-parcel", "Parcel dimensions in inches (LxWxH) used when a storefront sends no dimensions (default 12x9x6)", func(val string) error {
+		dims := strings.Split(strings.ToLower(val), "x")
+		if len(dims) != 3 {
+			return errors.New("must be in the form LxWxH")
+		}
+
+		parsed := make([]float64, 3)
+		for i, dim := range dims {
+			n, err := strconv.ParseFloat(strings.TrimSpace(dim), 64)
+			if err != nil || n <= 0 {
+				return errors.New("dimensions must be positive numbers")
+			}
+			parsed[i] = n
+		}
+
+		cfg.rates.parcel.length, cfg.rates.parcel.width, cfg.rates.parcel.height = parsed[0], parsed[1], parsed[2]
+		return nil
+	})
+
+	boxesPath := flag.String("boxes", "", "JSON file of the box inventory used to pack catalog items (defaults to a built-in inventory)")
+	dutiesPath := flag.String("duties", "", "JSON file of per-country de minimis thresholds and duty and tax rates (defaults to a built-in table)")
+
+	cfg.delivery.cutoff = delivery.DefaultCutoff
+	flag.Func("ship-cutoff", "Daily warehouse cutoff as HH:MM; parcels ready later ship the next business day (default 15:00)", func(val string) error {
+		t, err := time.Parse("15:04", val)
+		if err != nil {
+			return errors.New("must be a time of day as HH:MM")
+		}
+
+		cfg.delivery.cutoff = time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+		return nil
+	})
+	shipTimezone := flag.String("ship-timezone", "Local", "IANA time zone of the warehouse, used with the cutoff to date shipments")
+	holidaysPath := flag.String("holidays", "", "JSON file of YYYY-MM-DD dates the carrier does not accept or deliver mail (defaults to US federal holidays)")
+
+	flag.StringVar(&cfg.shopify.secret, "shopify-secret", "", "Shopify app secret used to verify carrier service callbacks")
+
+	flag.IntVar(&cfg.addresses.batchMaxSize, "address-batch-max-size", 100, "Maximum number of addresses in a batch request")
+	flag.IntVar(&cfg.addresses.batchConcurrency, "address-batch-concurrency", 8, "Maximum concurrent USPS requests per address batch")
+	flag.DurationVar(&cfg.addresses.batchTimeout, "address-batch-timeout", 2*time.Minute, "Maximum time to process an address batch")
+	flag.DurationVar(&cfg.addresses.cacheTTL, "address-cache-ttl", 30*24*time.Hour, "How long validated addresses are cached (0 disables the cache)")
+
+	flag.DurationVar(&cfg.tracking.interval, "tracking-interval", 30*time.Minute, "How often shipments in transit are tracked (0 disables polling)")
+	flag.IntVar(&cfg.tracking.batchSize, "tracking-batch-size", 100, "Maximum shipments tracked per poll")
+
+	flag.DurationVar(&cfg.mail.interval, "mail-interval", 10*time.Second, "How often to send queued emails (0 disables the mail worker)")
+	flag.IntVar(&cfg.mail.batchSize, "mail-batch-size", 50, "Maximum emails sent per run of the mail worker")
+	flag.IntVar(&cfg.mail.maxAttempts, "mail-max-attempts", 8, "Attempts before a queued email is given up as failed")
+	flag.DurationVar(&cfg.mail.retention, "mail-retention", 7*24*time.Hour, "How long sent and failed emails are kept (0 keeps them forever)")
 
 	flag.Func("cors-trusted-origins", "Trusted CORS origins (space separated)", func(val string) error {
 		cfg.cors.trustedOrigins = strings.Fields(val)
@@ -109,6 +228,46 @@ func main() {
 
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
 
+	cfg.packing.boxes = packing.DefaultBoxes
+
+	if *boxesPath != "" {
+		boxes, err := packing.LoadBoxes(*boxesPath)
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+		cfg.packing.boxes = boxes
+	}
+
+	location, err := time.LoadLocation(*shipTimezone)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+	cfg.delivery.location = location
+
+	cfg.delivery.holidays = delivery.FederalHolidays()
+
+	if *holidaysPath != "" {
+		holidays, err := delivery.LoadCalendar(*holidaysPath)
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+		cfg.delivery.holidays = holidays
+	}
+
+	cfg.duties.table = duties.DefaultTable
+
+	if *dutiesPath != "" {
+		table, err := duties.LoadTable(*dutiesPath)
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+		cfg.duties.table = table
+	}
+
 	db, err := openDB(cfg)
 	if err != nil {
 		logger.Error(err.Error())
@@ -118,8 +277,29 @@ func main() {
 
 	logger.Info("database connection pool established")
 
-	logger.Info("USPS Credentials Loaded")
-	fmt.Printf("Key: %s, Secret: %s", cfg.usps.key, cfg.usps.secret)
+	var (
+		oauthOpts    []usps.Option
+		addressesURL string
+		pricesURL    string
+		intlURL      string
+		trackingURL  string
+	)
+
+	if cfg.usps.baseURL != "" {
+		baseURL := strings.TrimRight(cfg.usps.baseURL, "/")
+
+		oauthOpts = append(oauthOpts, usps.WithBaseURL(baseURL+uspsApi.OAuthPath))
+		addressesURL = baseURL + uspsApi.AddressesPath
+		pricesURL = baseURL + uspsApi.PricesPath
+		intlURL = baseURL + uspsApi.InternationalPricesPath
+		trackingURL = baseURL + uspsApi.TrackingPath
+
+		logger.Info("using alternate USPS host", "url", baseURL)
+	}
+
+	tokens := uspsApi.NewTokenSource(cfg.usps.key, cfg.usps.secret, cfg.usps.tokenRefresh, oauthOpts...).WithLogger(logger)
+
+	logger.Info("USPS credentials loaded")
 
 	expvar.NewString("version").Set(version)
 
@@ -135,17 +315,26 @@ func main() {
 		return time.Now().Unix()
 	}))
 
-	mailer, err := mailer.New(cfg.smtp.host, cfg.smtp.port, cfg.smtp.username, cfg.smtp.password, cfg.smtp.sender)
+	sender, err := newMailSender(cfg, logger)
 	if err != nil {
 		logger.Error(err.Error())
 		os.Exit(1)
 	}
 
+	mailer := mailer.New(sender)
+
+	models := data.NewModels(db)
+
 	app := &application{
-		config: cfg,
-		logger: logger,
-		models: data.NewModels(db),
-		mailer: mailer,
+		config:         cfg,
+		logger:         logger,
+		models:         models,
+		mailer:         mailer,
+		notifier:       notify.New(mailOutbox{model: models.Outbox}, models),
+		addressClient:  uspsApi.NewAddressClient(tokens, addressesURL),
+		pricesClient:   uspsApi.NewPricesClient(tokens).WithBaseURL(pricesURL).WithInternationalBaseURL(intlURL),
+		trackingClient: uspsApi.NewTrackingClient(tokens).WithBaseURL(trackingURL),
+		estimator:      delivery.NewEstimator(cfg.delivery.cutoff, cfg.delivery.location, cfg.delivery.holidays),
 	}
 
 	err = app.serve()
@@ -155,6 +344,20 @@ func main() {
 	}
 }
 
+// newMailSender returns the mail transport chosen with -mail-transport.
+func newMailSender(cfg config, logger *slog.Logger) (mailer.Sender, error) {
+	switch cfg.mail.transport {
+	case "smtp":
+		return mailer.NewSMTPSender(cfg.smtp.host, cfg.smtp.port, cfg.smtp.username, cfg.smtp.password, cfg.smtp.sender)
+	case "dir":
+		return mailer.NewDirSender(cfg.mail.dir, cfg.smtp.sender)
+	case "memory":
+		return mailer.NewMemorySender(logger), nil
+	default:
+		return nil, fmt.Errorf("invalid mail transport %q (want smtp, dir or memory)", cfg.mail.transport)
+	}
+}
+
 func openDB(cfg config) (*sql.DB, error) {
 	db, err := sql.Open("postgres", cfg.db.dsn)
 	if err != nil {
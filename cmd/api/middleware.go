@@ -180,6 +180,38 @@ func (app *application) requirePermission(code string, next http.HandlerFunc) ht
 	return app.requireActivatedUser(fn)
 }
 
+// requireStoreKey authenticates storefront requests by the API key in the
+// X-API-Key header and only admits stores on the given platform.
+func (app *application) requireStoreKey(platform string, next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("X-API-Key")
+		if key == "" {
+			app.invalidStoreKeyResponse(w, r)
+			return
+		}
+
+		store, err := app.models.Stores.GetForKey(key)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				app.invalidStoreKeyResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		if store.Platform != platform {
+			app.notPermittedResponse(w, r)
+			return
+		}
+
+		r = app.contextSetStore(r, store)
+
+		next.ServeHTTP(w, r)
+	})
+}
+
 func (app *application) enableCORS(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Add("Vary", "Origin")
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"github.com/pistolricks/ShippingApi/internal/data"
+)
+
+// notifyCustomer emails the shipment's recipient in the background. kind may
+// be empty, in which case nothing is sent.
+func (app *application) notifyCustomer(shipment *data.Shipment, kind string) {
+	if kind == "" {
+		return
+	}
+
+	app.background(func() {
+		err := app.notifier.Notify(shipment, kind)
+		if err != nil {
+			app.logger.Error(err.Error(), "shipment_id", shipment.ID, "notification", kind)
+		}
+	})
+}
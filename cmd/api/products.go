@@ -0,0 +1,332 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/pistolricks/ShippingApi/internal/data"
+	"github.com/pistolricks/ShippingApi/internal/validator"
+)
+
+func (app *application) handleCreateProduct(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		SKU    string  `json:"sku"`
+		Name   string  `json:"name"`
+		Weight float64 `json:"weight"`
+		Length float64 `json:"length"`
+		Width  float64 `json:"width"`
+		Height float64 `json:"height"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	product := &data.Product{
+		SKU:    input.SKU,
+		Name:   input.Name,
+		Weight: input.Weight,
+		Length: input.Length,
+		Width:  input.Width,
+		Height: input.Height,
+	}
+
+	v := validator.New()
+
+	if data.ValidateProduct(v, product); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Products.Insert(product)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateSKU):
+			v.AddError("sku", "a product with this sku already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/api/v1/products/%d", product.ID))
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"product": product}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) handleShowProduct(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	product, err := app.models.Products.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"product": product}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) handleListProducts(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Search string
+		data.Filters
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	input.Search = app.readString(qs, "search", "")
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+
+	input.Filters.Sort = app.readString(qs, "sort", "sku")
+	input.Filters.SortSafelist = []string{"id", "sku", "name", "weight", "updated_at", "-id", "-sku", "-name", "-weight", "-updated_at"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	products, metadata, err := app.models.Products.GetAll(input.Search, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"products": products, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) handleUpdateProduct(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	product, err := app.models.Products.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		SKU     *string  `json:"sku"`
+		Name    *string  `json:"name"`
+		Weight  *float64 `json:"weight"`
+		Length  *float64 `json:"length"`
+		Width   *float64 `json:"width"`
+		Height  *float64 `json:"height"`
+		Version *int     `json:"version"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Version != nil && *input.Version != product.Version {
+		app.editConflictResponse(w, r)
+		return
+	}
+
+	if input.SKU != nil {
+		product.SKU = *input.SKU
+	}
+	if input.Name != nil {
+		product.Name = *input.Name
+	}
+	if input.Weight != nil {
+		product.Weight = *input.Weight
+	}
+	if input.Length != nil {
+		product.Length = *input.Length
+	}
+	if input.Width != nil {
+		product.Width = *input.Width
+	}
+	if input.Height != nil {
+		product.Height = *input.Height
+	}
+
+	v := validator.New()
+
+	if data.ValidateProduct(v, product); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Products.Update(product)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateSKU):
+			v.AddError("sku", "a product with this sku already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"product": product}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) handleDeleteProduct(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Products.Delete(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "product successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// handleImportProducts creates or updates products from a CSV sent either as
+// a text/csv body or as the "file" field of a multipart form. Nothing is
+// imported unless every row is valid.
+func (app *application) handleImportProducts(w http.ResponseWriter, r *http.Request) {
+	body, ok, err := app.openCSVUpload(w, r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if !ok {
+		app.badRequestResponse(w, r, errors.New("body must be text/csv or multipart/form-data"))
+		return
+	}
+	defer body.Close()
+
+	products, err := readProductCSV(body)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	v.Check(len(products) > 0, "file", "must contain at least 1 product")
+
+	seen := make(map[string]int, len(products))
+
+	for i, product := range products {
+		row := i + 2 // 1-based, after the header row
+
+		pv := validator.New()
+		data.ValidateProduct(pv, product)
+
+		for field, message := range pv.Errors {
+			v.AddError(fmt.Sprintf("row %d %s", row, field), message)
+		}
+
+		if first, ok := seen[product.SKU]; ok {
+			v.AddError(fmt.Sprintf("row %d sku", row), fmt.Sprintf("duplicates row %d", first))
+		}
+		seen[product.SKU] = row
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	created, updated, err := app.models.Products.Upsert(products)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"summary": map[string]int{"created": created, "updated": updated}}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// readProductCSV parses CSV with a header row naming the product fields
+// (sku, name, weight, length, width, height) in any order. Header names are
+// case-insensitive.
+func readProductCSV(r io.Reader) ([]*data.Product, error) {
+	rows, err := parseCSV(r, productCSVColumns)
+	if err != nil {
+		return nil, err
+	}
+
+	products := make([]*data.Product, len(rows))
+	for i := range rows {
+		products[i] = &rows[i]
+	}
+
+	return products, nil
+}
+
+var productCSVColumns = map[string]func(*data.Product, string) error{
+	"sku":    func(p *data.Product, s string) error { p.SKU = s; return nil },
+	"name":   func(p *data.Product, s string) error { p.Name = s; return nil },
+	"weight": func(p *data.Product, s string) (err error) { p.Weight, err = parseCSVNumber(s); return err },
+	"length": func(p *data.Product, s string) (err error) { p.Length, err = parseCSVNumber(s); return err },
+	"width":  func(p *data.Product, s string) (err error) { p.Width, err = parseCSVNumber(s); return err },
+	"height": func(p *data.Product, s string) (err error) { p.Height, err = parseCSVNumber(s); return err },
+}
+
+func parseCSVNumber(s string) (float64, error) {
+	if s == "" {
+		return 0, nil
+	}
+
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, errors.New("must be a number")
+	}
+
+	return n, nil
+}
@@ -0,0 +1,668 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pistolricks/ShippingApi/internal/customs"
+	"github.com/pistolricks/ShippingApi/internal/duties"
+	"github.com/pistolricks/ShippingApi/internal/packing"
+	uspsApi "github.com/pistolricks/ShippingApi/internal/usps"
+	"github.com/pistolricks/ShippingApi/internal/validator"
+)
+
+type rateInput struct {
+	OriginZIPCode      string `json:"originZIPCode"`
+	DestinationZIPCode string `json:"destinationZIPCode"`
+	// DestinationCountryCode and ForeignPostalCode route the request to
+	// the international prices API when the destination is outside the US.
+	DestinationCountryCode string       `json:"destinationCountryCode,omitempty"`
+	ForeignPostalCode      string       `json:"foreignPostalCode,omitempty"`
+	Weight                 float64      `json:"weight"`
+	Length                 float64      `json:"length"`
+	Width                  float64      `json:"width"`
+	Height                 float64      `json:"height"`
+	MailClass              string       `json:"mailClass,omitempty"`
+	ProcessingCategory     string       `json:"processingCategory,omitempty"`
+	MailingDate            string       `json:"mailingDate,omitempty"`
+	Shop                   bool         `json:"shop,omitempty"`
+	Items                  []rateItem   `json:"items,omitempty"`
+	Parcels                []rateParcel `json:"parcels,omitempty"`
+	// Customs lists the goods of an international order so that duties and
+	// taxes can be estimated alongside each rate.
+	Customs []rateCustomsItem `json:"customs,omitempty"`
+}
+
+type rateCustomsItem struct {
+	Description   string  `json:"description"`
+	TariffNumber  string  `json:"tariffNumber"`
+	OriginCountry string  `json:"originCountry"`
+	Quantity      int     `json:"quantity"`
+	UnitValue     float64 `json:"unitValue"`
+}
+
+type rateParcel struct {
+	Weight float64 `json:"weight"`
+	Length float64 `json:"length"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+type rateItem struct {
+	SKU string `json:"sku"`
+	Qty int    `json:"qty"`
+}
+
+// maxPackedUnits caps the number of item units packed for one rate request.
+// Packing time grows with the cube of the unit count, so this keeps a single
+// request from tying up the server.
+const maxPackedUnits = 100
+
+// packItems packs the catalog products listed in input.Items into boxes
+// from the configured inventory. A single box becomes the parcel of input
+// and several boxes become input.Parcels. Unknown SKUs and bad quantities
+// are recorded in v.
+func (app *application) packItems(v *validator.Validator, input *rateInput) ([]packing.Parcel, error) {
+	if len(input.Items) == 0 {
+		return nil, nil
+	}
+
+	skus := make([]string, 0, len(input.Items))
+	units := 0
+
+	for i, item := range input.Items {
+		v.Check(item.SKU != "", fmt.Sprintf("items[%d].sku", i), "must be provided")
+		v.Check(item.Qty > 0, fmt.Sprintf("items[%d].qty", i), "must be greater than zero")
+
+		skus = append(skus, item.SKU)
+		units += max(item.Qty, 0)
+	}
+
+	v.Check(len(input.Parcels) == 0, "items", "must not be combined with parcels")
+	v.Check(units <= maxPackedUnits, "items", fmt.Sprintf("must not contain more than %d units", maxPackedUnits))
+
+	if !v.Valid() {
+		return nil, nil
+	}
+
+	products, err := app.models.Products.GetForSKUs(skus)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []packing.Item
+
+	for i, item := range input.Items {
+		product, ok := products[item.SKU]
+		if !ok {
+			v.AddError(fmt.Sprintf("items[%d].sku", i), "is not in the product catalog")
+			continue
+		}
+
+		for range item.Qty {
+			items = append(items, packing.Item{
+				SKU:    product.SKU,
+				Length: product.Length,
+				Width:  product.Width,
+				Height: product.Height,
+				Weight: product.Weight,
+			})
+		}
+	}
+
+	if !v.Valid() {
+		return nil, nil
+	}
+
+	parcels, err := packing.Pack(app.config.packing.boxes, items)
+	if err != nil {
+		if errors.Is(err, packing.ErrItemTooLarge) {
+			v.AddError("items", err.Error())
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if len(parcels) > 1 {
+		for _, parcel := range parcels {
+			input.Parcels = append(input.Parcels, rateParcel{
+				Weight: parcel.Weight,
+				Length: parcel.Box.Length,
+				Width:  parcel.Box.Width,
+				Height: parcel.Box.Height,
+			})
+		}
+		return parcels, nil
+	}
+
+	box := parcels[0].Box
+
+	input.Weight = parcels[0].Weight
+	input.Length, input.Width, input.Height = box.Length, box.Width, box.Height
+
+	return parcels, nil
+}
+
+// domesticRatesRequest builds the USPS base-rates request for input, filling
+// in the account details from the config and the defaults for any optional
+// fields left empty. The parcel is classified to choose its rate indicator
+// and, unless input names one, its processing category.
+func (app *application) domesticRatesRequest(input rateInput) (uspsApi.DomesticBaseRatesRequest, uspsApi.Classification) {
+	req := uspsApi.DomesticBaseRatesRequest{
+		OriginZIPCode:                input.OriginZIPCode,
+		DestinationZIPCode:           input.DestinationZIPCode,
+		Weight:                       input.Weight,
+		Length:                       input.Length,
+		Width:                        input.Width,
+		Height:                       input.Height,
+		MailClass:                    input.MailClass,
+		ProcessingCategory:           input.ProcessingCategory,
+		DestinationEntryFacilityType: "NONE",
+		PriceType:                    "COMMERCIAL",
+		MailingDate:                  input.MailingDate,
+		AccountType:                  app.config.usps.accountType,
+		AccountNumber:                app.config.usps.accountNumber,
+		CubicPricing:                 app.config.usps.cubicPricing,
+	}
+
+	if req.MailClass == "" {
+		req.MailClass = uspsApi.MailClassGroundAdvantage
+	}
+
+	if req.MailingDate == "" {
+		req.MailingDate = uspsApi.Today()
+	}
+
+	class := req.Classify()
+
+	return req, class
+}
+
+// internationalRatesRequest builds the USPS international base-rates
+// request for input, filling in the account details from the config and
+// defaulting to Priority Mail International.
+func (app *application) internationalRatesRequest(input rateInput) (uspsApi.InternationalBaseRatesRequest, uspsApi.Classification) {
+	class := uspsApi.Classify(input.Weight, input.Length, input.Width, input.Height)
+
+	req := uspsApi.InternationalBaseRatesRequest{
+		OriginZIPCode:                input.OriginZIPCode,
+		ForeignPostalCode:            strings.TrimSpace(input.ForeignPostalCode),
+		DestinationCountryCode:       strings.ToUpper(input.DestinationCountryCode),
+		Weight:                       input.Weight,
+		Length:                       input.Length,
+		Width:                        input.Width,
+		Height:                       input.Height,
+		MailClass:                    input.MailClass,
+		ProcessingCategory:           input.ProcessingCategory,
+		RateIndicator:                uspsApi.RateIndicatorSinglePiece,
+		DestinationEntryFacilityType: "NONE",
+		PriceType:                    "COMMERCIAL",
+		MailingDate:                  input.MailingDate,
+		AccountType:                  app.config.usps.accountType,
+		AccountNumber:                app.config.usps.accountNumber,
+	}
+
+	if req.MailClass == "" {
+		req.MailClass = uspsApi.MailClassPriorityMailInternational
+	}
+
+	if req.ProcessingCategory == "" {
+		req.ProcessingCategory = uspsApi.ProcessingCategoryNonMachinable
+		if class.ProcessingCategory == uspsApi.ProcessingCategoryMachinable {
+			req.ProcessingCategory = uspsApi.ProcessingCategoryMachinable
+		}
+	}
+
+	if req.MailingDate == "" {
+		req.MailingDate = uspsApi.Today()
+	}
+
+	return req, class
+}
+
+// quoteInternationalRates is quoteRates for international requests.
+func (app *application) quoteInternationalRates(ctx context.Context, req uspsApi.InternationalBaseRatesRequest, shop bool) ([]uspsApi.Quote, map[string]string, error) {
+	if shop {
+		res, err := app.pricesClient.ShopInternationalBaseRates(ctx, req)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		app.estimateDelivery(req.MailingDate, res.Quotes)
+		return res.Quotes, res.Errors, nil
+	}
+
+	res, err := app.pricesClient.SearchInternationalBaseRates(ctx, req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	quotes := res.Quotes(req.MailClass)
+	app.estimateDelivery(req.MailingDate, quotes)
+
+	return quotes, nil, nil
+}
+
+// quoteRates prices req, either for its own mail class or, when shop is set,
+// across mailClasses (every rate-shopping mail class when empty). The
+// returned map holds the mail classes USPS could not price while shopping.
+func (app *application) quoteRates(ctx context.Context, req uspsApi.DomesticBaseRatesRequest, shop bool, mailClasses ...string) ([]uspsApi.Quote, map[string]string, error) {
+	if shop {
+		res, err := app.pricesClient.ShopDomesticBaseRates(ctx, req, mailClasses...)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		app.estimateDelivery(req.MailingDate, res.Quotes)
+		return res.Quotes, res.Errors, nil
+	}
+
+	res, err := app.pricesClient.SearchDomesticBaseRates(ctx, req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	quotes := res.Quotes(req.MailClass)
+	app.estimateDelivery(req.MailingDate, quotes)
+
+	return quotes, nil, nil
+}
+
+// estimateDelivery sets the expected delivery window of each quote whose
+// mail class has a service standard. Parcels are taken to be ready now, or
+// at the start of mailingDate when it is later.
+func (app *application) estimateDelivery(mailingDate string, quotes []uspsApi.Quote) {
+	ready := app.readyAt(mailingDate)
+
+	for i := range quotes {
+		if standard, ok := uspsApi.ServiceStandard(quotes[i].MailClass); ok {
+			window := app.estimator.Estimate(ready, standard)
+			quotes[i].Delivery = &window
+		}
+	}
+}
+
+func (app *application) readyAt(mailingDate string) time.Time {
+	now := time.Now()
+
+	date, err := time.ParseInLocation(uspsApi.MailingDateLayout, mailingDate, app.estimator.Location)
+	if err != nil || !date.After(now) {
+		return now
+	}
+
+	return date
+}
+
+// storefrontQuotes shops rates for a checkout on behalf of the storefront
+// adapters. When every line item in input.Items is in the product catalog,
+// the items are packed into boxes from the inventory; otherwise the parcel
+// of input is used, with the configured default parcel size if it has no
+// dimensions. Domestic checkouts are only offered StorefrontMailClasses, and
+// an order packed into several boxes is offered the total for each mail
+// class that priced every box. A parcel that fails validation is reported
+// as an error, since storefronts have no way to show field errors to the
+// shopper.
+func (app *application) storefrontQuotes(ctx context.Context, input rateInput) ([]uspsApi.Quote, error) {
+	if len(input.Items) > 0 {
+		packed := input
+		v := validator.New()
+
+		parcels, err := app.packItems(v, &packed)
+		switch {
+		case err != nil:
+			app.logger.Error(err.Error())
+		case v.Valid() && len(parcels) > 0:
+			input = packed
+		}
+
+		input.Items = nil
+	}
+
+	if input.Length <= 0 || input.Width <= 0 || input.Height <= 0 {
+		input.Length = app.config.rates.parcel.length
+		input.Width = app.config.rates.parcel.width
+		input.Height = app.config.rates.parcel.height
+	}
+
+	v := validator.New()
+
+	if uspsApi.IsInternational(input.DestinationCountryCode) {
+		if len(input.Parcels) > 0 {
+			return nil, errors.New("unpriceable order: multiple parcels are only supported for domestic destinations")
+		}
+
+		req, _ := app.internationalRatesRequest(input)
+
+		if uspsApi.ValidateInternationalBaseRatesRequest(v, req); !v.Valid() {
+			return nil, fmt.Errorf("unpriceable parcel: %v", v.Errors)
+		}
+
+		quotes, _, err := app.quoteInternationalRates(ctx, req, true)
+		return quotes, err
+	}
+
+	if len(input.Parcels) > 0 {
+		return app.storefrontParcelQuotes(ctx, input)
+	}
+
+	req, _ := app.domesticRatesRequest(input)
+
+	if uspsApi.ValidateDomesticBaseRatesRequest(v, req); !v.Valid() {
+		return nil, fmt.Errorf("unpriceable parcel: %v", v.Errors)
+	}
+
+	quotes, _, err := app.quoteRates(ctx, req, true, uspsApi.StorefrontMailClasses...)
+	return quotes, err
+}
+
+// storefrontParcelQuotes prices each parcel of input and turns the total of
+// each mail class into a single quote for the whole order.
+func (app *application) storefrontParcelQuotes(ctx context.Context, input rateInput) ([]uspsApi.Quote, error) {
+	reqs := make([]uspsApi.DomesticBaseRatesRequest, len(input.Parcels))
+
+	for i, parcel := range input.Parcels {
+		parcelInput := input
+		parcelInput.Parcels = nil
+		parcelInput.Weight = parcel.Weight
+		parcelInput.Length = parcel.Length
+		parcelInput.Width = parcel.Width
+		parcelInput.Height = parcel.Height
+
+		reqs[i], _ = app.domesticRatesRequest(parcelInput)
+
+		v := validator.New()
+		if uspsApi.ValidateDomesticBaseRatesRequest(v, reqs[i]); !v.Valid() {
+			return nil, fmt.Errorf("unpriceable parcel %d: %v", i, v.Errors)
+		}
+	}
+
+	res, err := app.pricesClient.QuoteParcels(ctx, reqs, true, uspsApi.StorefrontMailClasses...)
+	if err != nil {
+		return nil, err
+	}
+
+	quotes := make([]uspsApi.Quote, 0, len(res.Totals))
+
+	for _, total := range res.Totals {
+		quotes = append(quotes, uspsApi.Quote{
+			MailClass:   total.MailClass,
+			Description: uspsApi.MailClassName(total.MailClass),
+			Price:       total.Price,
+			Currency:    total.Currency,
+		})
+	}
+
+	app.estimateDelivery(reqs[0].MailingDate, quotes)
+
+	return quotes, nil
+}
+
+func (app *application) handleShippingRates(w http.ResponseWriter, r *http.Request) {
+	var input rateInput
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	packed, err := app.packItems(v, &input)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if uspsApi.IsInternational(input.DestinationCountryCode) {
+		app.internationalShippingRates(w, r, input, packed)
+		return
+	}
+
+	if v.Check(len(input.Customs) == 0, "customs", "must only be provided for international destinations"); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if len(input.Parcels) > 0 {
+		app.quoteParcels(w, r, input, packed)
+		return
+	}
+
+	req, class := app.domesticRatesRequest(input)
+
+	if uspsApi.ValidateDomesticBaseRatesRequest(v, req); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// When shopping, mail classes USPS could not price are reported under
+	// "errors" rather than failing the request.
+	quotes, errs, err := app.quoteRates(r.Context(), req, input.Shop)
+	if err != nil {
+		app.pricesErrorResponse(w, r, err)
+		return
+	}
+
+	env := envelope{"rates": quotes, "package": class}
+	if len(packed) > 0 {
+		env["packing"] = packed
+	}
+	if len(errs) > 0 {
+		env["errors"] = errs
+	}
+
+	err = app.writeJSON(w, http.StatusOK, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// quoteParcels quotes every parcel of input concurrently and responds with a
+// per-parcel breakdown and the total for each mail class that priced every
+// parcel. Parcels that can't be priced are reported alongside the others.
+func (app *application) quoteParcels(w http.ResponseWriter, r *http.Request, input rateInput, packed []packing.Parcel) {
+	v := validator.New()
+
+	v.Check(len(input.Parcels) <= uspsApi.MaxParcels, "parcels", fmt.Sprintf("must not contain more than %d parcels", uspsApi.MaxParcels))
+
+	// Each parcel carries its own weight and dimensions, so top-level ones
+	// would be silently ignored.
+	for key, value := range map[string]float64{"weight": input.Weight, "length": input.Length, "width": input.Width, "height": input.Height} {
+		v.Check(value == 0, key, "must not be combined with parcels")
+	}
+
+	reqs := make([]uspsApi.DomesticBaseRatesRequest, len(input.Parcels))
+
+	for i, parcel := range input.Parcels {
+		parcelInput := input
+		parcelInput.Parcels = nil
+		parcelInput.Weight = parcel.Weight
+		parcelInput.Length = parcel.Length
+		parcelInput.Width = parcel.Width
+		parcelInput.Height = parcel.Height
+
+		reqs[i], _ = app.domesticRatesRequest(parcelInput)
+
+		pv := validator.New()
+		uspsApi.ValidateDomesticBaseRatesRequest(pv, reqs[i])
+
+		for key, message := range pv.Errors {
+			v.AddError(fmt.Sprintf("parcels[%d].%s", i, key), message)
+		}
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	res, err := app.pricesClient.QuoteParcels(r.Context(), reqs, input.Shop)
+	if err != nil {
+		app.pricesErrorResponse(w, r, err)
+		return
+	}
+
+	for i := range res.Parcels {
+		app.estimateDelivery(reqs[i].MailingDate, res.Parcels[i].Quotes)
+	}
+
+	ready := app.readyAt(reqs[0].MailingDate)
+
+	for i := range res.Totals {
+		if standard, ok := uspsApi.ServiceStandard(res.Totals[i].MailClass); ok {
+			window := app.estimator.Estimate(ready, standard)
+			res.Totals[i].Delivery = &window
+		}
+	}
+
+	failed := res.Failed()
+
+	env := envelope{
+		"parcels": res.Parcels,
+		"totals":  res.Totals,
+		"summary": map[string]int{
+			"total":     len(res.Parcels),
+			"succeeded": len(res.Parcels) - failed,
+			"failed":    failed,
+		},
+	}
+	if len(packed) > 0 {
+		env["packing"] = packed
+	}
+
+	err = app.writeJSON(w, http.StatusOK, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// internationalShippingRates quotes a single parcel to a destination outside
+// the US through the international prices API.
+func (app *application) internationalShippingRates(w http.ResponseWriter, r *http.Request, input rateInput, packed []packing.Parcel) {
+	v := validator.New()
+
+	if len(input.Parcels) > 0 {
+		v.AddError("parcels", "multiple parcels are only supported for domestic destinations")
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	req, class := app.internationalRatesRequest(input)
+
+	uspsApi.ValidateInternationalBaseRatesRequest(v, req)
+	items := customsItems(v, input.Customs)
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	quotes, errs, err := app.quoteInternationalRates(r.Context(), req, input.Shop)
+	if err != nil {
+		app.pricesErrorResponse(w, r, err)
+		return
+	}
+
+	env := envelope{"rates": quotes, "package": class}
+	if len(packed) > 0 {
+		env["packing"] = packed
+	}
+
+	if len(items) > 0 {
+		costs, err := app.estimateLandedCosts(req.DestinationCountryCode, items, quotes)
+		switch {
+		case errors.Is(err, duties.ErrNoRates):
+			if errs == nil {
+				errs = make(map[string]string)
+			}
+			errs["landedCosts"] = err.Error()
+		case err != nil:
+			app.serverErrorResponse(w, r, err)
+			return
+		default:
+			env["landedCosts"] = costs
+		}
+	}
+	if len(errs) > 0 {
+		env["errors"] = errs
+	}
+
+	err = app.writeJSON(w, http.StatusOK, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// customsItems validates the customs goods of a rate request and merges them
+// into declaration items. Goods without an origin are taken to be made in
+// the US.
+func customsItems(v *validator.Validator, goods []rateCustomsItem) []customs.Item {
+	if len(goods) == 0 {
+		return nil
+	}
+
+	v.Check(len(goods) <= customs.MaxItems, "customs", fmt.Sprintf("must not contain more than %d items", customs.MaxItems))
+
+	lines := make([]customs.LineItem, len(goods))
+
+	for i, item := range goods {
+		key := fmt.Sprintf("customs[%d]", i)
+
+		if item.OriginCountry == "" {
+			item.OriginCountry = "US"
+		}
+
+		v.Check(item.Quantity > 0, key+".quantity", "must be greater than zero")
+		v.Check(item.UnitValue >= 0, key+".unitValue", "must not be negative")
+		v.Check(validator.IsCountryCode(item.OriginCountry), key+".originCountry", "must be an ISO 3166-1 alpha-2 country code")
+
+		if tariff := strings.ReplaceAll(item.TariffNumber, ".", ""); tariff != "" {
+			v.Check(validator.Matches(tariff, customs.TariffNumberRX), key+".tariffNumber", "must be a 6, 8 or 10 digit HS code")
+		}
+
+		lines[i] = customs.LineItem{
+			Description:   item.Description,
+			TariffNumber:  item.TariffNumber,
+			OriginCountry: item.OriginCountry,
+			Quantity:      item.Quantity,
+			UnitValue:     item.UnitValue,
+		}
+	}
+
+	return customs.Build(customs.Declaration{}, lines).Items
+}
+
+// landedCost is the duty and tax estimate for one rate.
+type landedCost struct {
+	MailClass string `json:"mailClass"`
+	SKU       string `json:"SKU"`
+	*duties.Estimate
+}
+
+// estimateLandedCosts estimates duties and taxes on items for each quote.
+// Most countries tax shipping as well as the goods, so every rate gets its
+// own estimate.
+func (app *application) estimateLandedCosts(countryCode string, items []customs.Item, quotes []uspsApi.Quote) ([]landedCost, error) {
+	costs := make([]landedCost, 0, len(quotes))
+
+	for _, quote := range quotes {
+		est, err := app.config.duties.table.Estimate(countryCode, items, "USD", quote.Price)
+		if err != nil {
+			return nil, err
+		}
+
+		costs = append(costs, landedCost{MailClass: quote.MailClass, SKU: quote.SKU, Estimate: est})
+	}
+
+	return costs, nil
+}
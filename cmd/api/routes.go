@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"github.com/julienschmidt/httprouter"
+	"github.com/pistolricks/ShippingApi/internal/data"
 )
 
 func (app *application) routes() http.Handler {
@@ -16,7 +17,38 @@ func (app *application) routes() http.Handler {
 	router.HandlerFunc(http.MethodGet, "/api/v1/healthcheck", app.healthcheckHandler)
 
 	router.HandlerFunc(http.MethodPost, "/api/v1/addresses", app.handleStandardAddress)
+	router.HandlerFunc(http.MethodPost, "/api/v1/addresses/batch", app.requirePermission("addresses:batch", app.handleStandardAddressBatch))
 	router.HandlerFunc(http.MethodPost, "/api/v1/rates", app.handleShippingRates)
+	router.HandlerFunc(http.MethodPost, "/api/v1/shopify/rates", app.handleShopifyRates)
+	router.HandlerFunc(http.MethodPost, "/api/v1/woocommerce/rates", app.requireStoreKey(data.StorePlatformWooCommerce, app.handleWooCommerceRates))
+	router.HandlerFunc(http.MethodPost, "/api/v1/shippo/webhooks", app.handleShippoWebhook)
+
+	router.HandlerFunc(http.MethodGet, "/api/v1/shipments", app.requirePermission("shipments:read", app.handleListShipments))
+	router.HandlerFunc(http.MethodPost, "/api/v1/shipments", app.requirePermission("shipments:write", app.handleCreateShipment))
+	router.HandlerFunc(http.MethodGet, "/api/v1/shipments/:id", app.requirePermission("shipments:read", app.handleShowShipment))
+	router.HandlerFunc(http.MethodPatch, "/api/v1/shipments/:id", app.requirePermission("shipments:write", app.handleUpdateShipment))
+	router.HandlerFunc(http.MethodPost, "/api/v1/shipments/:id/rates", app.requirePermission("shipments:write", app.handleRateShipment))
+	router.HandlerFunc(http.MethodPut, "/api/v1/shipments/:id/customs", app.requirePermission("shipments:write", app.handleUpdateShipmentCustoms))
+	router.HandlerFunc(http.MethodPost, "/api/v1/shipments/:id/reconcile", app.requirePermission("shipments:write", app.handleReconcileShipmentLabel))
+	router.HandlerFunc(http.MethodGet, "/api/v1/shipments/:id/tracking", app.requirePermission("shipments:read", app.handleShowShipmentTracking))
+	router.HandlerFunc(http.MethodPost, "/api/v1/shipments/:id/tracking/refresh", app.requirePermission("shipments:write", app.handleRefreshShipmentTracking))
+	router.HandlerFunc(http.MethodPost, "/api/v1/labels", app.requirePermission("shipments:write", app.handlePurchaseLabel))
+	router.HandlerFunc(http.MethodGet, "/api/v1/labels/:id", app.requirePermission("shipments:read", app.handleShowLabel))
+
+	router.HandlerFunc(http.MethodGet, "/api/v1/products", app.requirePermission("products:read", app.handleListProducts))
+	router.HandlerFunc(http.MethodPost, "/api/v1/products", app.requirePermission("products:write", app.handleCreateProduct))
+	router.HandlerFunc(http.MethodPost, "/api/v1/products/import", app.requirePermission("products:write", app.handleImportProducts))
+	router.HandlerFunc(http.MethodGet, "/api/v1/products/:id", app.requirePermission("products:read", app.handleShowProduct))
+	router.HandlerFunc(http.MethodPatch, "/api/v1/products/:id", app.requirePermission("products:write", app.handleUpdateProduct))
+	router.HandlerFunc(http.MethodDelete, "/api/v1/products/:id", app.requirePermission("products:write", app.handleDeleteProduct))
+
+	router.HandlerFunc(http.MethodGet, "/api/v1/stores", app.requirePermission("stores:read", app.handleListStores))
+	router.HandlerFunc(http.MethodPost, "/api/v1/stores", app.requirePermission("stores:write", app.handleCreateStore))
+	router.HandlerFunc(http.MethodPatch, "/api/v1/stores/:id", app.requirePermission("stores:write", app.handleUpdateStore))
+	router.HandlerFunc(http.MethodPost, "/api/v1/stores/:id/key", app.requirePermission("stores:write", app.handleRotateStoreKey))
+
+	router.HandlerFunc(http.MethodGet, "/api/v1/admin/mail", app.requirePermission("mail:read", app.handleListMail))
+	router.HandlerFunc(http.MethodPost, "/api/v1/admin/mail/:id/resend", app.requirePermission("mail:write", app.handleResendMail))
 
 	router.HandlerFunc(http.MethodPost, "/api/v1/users", app.registerUserHandler)
 	router.HandlerFunc(http.MethodPut, "/api/v1/users/activated", app.activateUserHandler)
@@ -20,6 +20,13 @@ func (app *application) serve() error {
 		WriteTimeout: 10 * time.Second,
 	}
 
+	// Canceling ctx stops the background workers; serve waits for them
+	// along with the other background tasks before it returns.
+	ctx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+
+	app.startWorkers(ctx)
+
 	shutdownError := make(chan error)
 
 	go func() {
@@ -29,6 +36,8 @@ func (app *application) serve() error {
 
 		app.logger.Info("caught signal", "signal", s.String())
 
+		stopWorkers()
+
 		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 		defer cancel()
 
@@ -59,3 +68,23 @@ func (app *application) serve() error {
 
 	return nil
 }
+
+// startWorkers starts the periodic background jobs that are enabled in the
+// config. They run until ctx is canceled.
+func (app *application) startWorkers(ctx context.Context) {
+	if app.config.addresses.cacheTTL > 0 {
+		app.every(ctx, time.Hour, app.purgeAddressCache)
+	}
+
+	if app.config.tracking.interval > 0 {
+		app.every(ctx, app.config.tracking.interval, app.pollTracking)
+	}
+
+	if app.config.mail.interval > 0 {
+		app.every(ctx, app.config.mail.interval, app.deliverMail)
+	}
+
+	if app.config.mail.retention > 0 {
+		app.every(ctx, time.Hour, app.purgeMail)
+	}
+}
@@ -1,49 +1,366 @@
 package main
 
 import (
-	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"time"
 
-	uspsApi "github.com/pistolricks/ShippingApi/internal/usps"
+	"github.com/pistolricks/ShippingApi/internal/customs"
+	"github.com/pistolricks/ShippingApi/internal/data"
+	"github.com/pistolricks/ShippingApi/internal/shippo"
+	"github.com/pistolricks/ShippingApi/internal/validator"
 )
 
-func (app *application) handleShippingRates(w http.ResponseWriter, r *http.Request) {
-	// Create the client (already provided by app helper):
-	ratesClient := app.postalRatesClient()
-
-	// Build request according to USPS Domestic Prices v3 API:
-	reqBody := uspsApi.DomesticBaseRatesRequest{
-		OriginZIPCode:                 "30301",
-		DestinationZIPCode:            "90210",
-		Weight:                        1.0, // ounces, example
-		Length:                        2.0,
-		Width:                         3.0,
-		Height:                        3.0,
-		MailClass:                     "USPS_GROUND_ADVANTAGE",
-		ProcessingCategory:            "MACHINABLE",
-		RateIndicator:                 "SP",
-		DestinationEntryFacilityType:  "NONE",
-		PriceType:                     "COMMERCIAL",
-		MailingDate:                   "2025-12-20",
-		AccountType:                   "MID",
-		AccountNumber:                 "903950522",
-		HasNonstandardCharacteristics: false,
-		// Add other USPS-supported params as needed (serviceType, machinable, dimensions, etc.)
-	}
-
-	// Call the endpoint
-	fmt.Printf("%s", reqBody)
-
-	res, err := ratesClient.SearchDomesticBaseRates(context.Background(), reqBody)
+func (app *application) handleCreateShipment(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Reference   string        `json:"reference"`
+		StoreID     *int64        `json:"store_id"`
+		AddressFrom data.Address  `json:"address_from"`
+		AddressTo   data.Address  `json:"address_to"`
+		Parcels     []data.Parcel `json:"parcels"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	shipment := &data.Shipment{
+		Status:      data.ShipmentStatusDraft,
+		Reference:   input.Reference,
+		StoreID:     input.StoreID,
+		AddressFrom: input.AddressFrom,
+		AddressTo:   input.AddressTo,
+		Parcels:     input.Parcels,
+	}
+
+	v := validator.New()
+
+	if data.ValidateShipment(v, shipment); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if shipment.StoreID != nil {
+		_, err = app.models.Stores.Get(*shipment.StoreID)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				v.AddError("store_id", "no matching store found")
+				app.failedValidationResponse(w, r, v.Errors)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+	}
+
+	err = app.models.Shipments.Insert(shipment)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/api/v1/shipments/%d", shipment.ID))
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"shipment": shipment}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) handleShowShipment(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	shipment, err := app.models.Shipments.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"shipment": shipment}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) handleListShipments(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Status string
+		data.Filters
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	input.Status = app.readString(qs, "status", "")
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+
+	input.Filters.Sort = app.readString(qs, "sort", "-created_at")
+	input.Filters.SortSafelist = []string{"id", "created_at", "updated_at", "status", "-id", "-created_at", "-updated_at", "-status"}
+
+	if input.Status != "" {
+		v.Check(validator.PermittedValue(input.Status, data.ShipmentStatuses...), "status", "invalid status")
+	}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	shipments, metadata, err := app.models.Shipments.GetAll(input.Status, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"shipments": shipments, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) handleUpdateShipment(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	shipment, err := app.models.Shipments.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		Status    *string `json:"status"`
+		Reference *string `json:"reference"`
+		Version   *int    `json:"version"`
+	}
+
+	err = app.readJSON(w, r, &input)
 	if err != nil {
 		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Version != nil && *input.Version != shipment.Version {
+		app.editConflictResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+
+	if input.Reference != nil {
+		shipment.Reference = *input.Reference
+	}
+
+	if input.Status != nil && *input.Status != shipment.Status {
+		// Rating and labeling go through their own endpoints so that the
+		// Shippo shipment and label are recorded alongside the new status.
+		// A shipment stuck purchasing can be voided, but not while its label
+		// may still be being bought.
+		switch {
+		case *input.Status == data.ShipmentStatusRated, *input.Status == data.ShipmentStatusPurchasing, *input.Status == data.ShipmentStatusLabeled:
+			v.AddError("status", "must be set by rating or purchasing a label")
+		case *input.Status == data.ShipmentStatusDraft:
+			v.AddError("status", "is only set by changing the customs declaration")
+		case shipment.Status == data.ShipmentStatusPurchasing && time.Since(shipment.UpdatedAt) < purchaseTimeout:
+			v.AddError("status", "a label purchase is still in progress, please try again later")
+		default:
+			if err := shipment.Transition(*input.Status); err != nil {
+				v.AddError("status", err.Error())
+			}
+		}
+	}
+
+	if data.ValidateShipment(v, shipment); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Shipments.Update(shipment)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"shipment": shipment}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) handleRateShipment(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	shipment, err := app.models.Shipments.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	v := validator.New()
+
+	// A purchasing shipment may only go back to rated when its purchase
+	// fails, not by being rated again.
+	v.Check(shipment.Status != data.ShipmentStatusPurchasing, "status", "a label is being purchased for this shipment")
+	v.Check(shipment.CanTransition(data.ShipmentStatusRated), "status", "shipment can no longer be rated")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	client, err := app.shippoClient()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// Rate international shipments with their customs declaration, so the
+	// rates match what the label will cost.
+	err = app.createShippoCustoms(client, shipment)
+	if err != nil {
+		switch {
+		case shippo.IsRejected(err):
+			app.badRequestResponse(w, r, err)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var rated *shippo.Shipment
+
+	if shipment.ShippoCustomsID != "" {
+		rated, err = client.CreateShipmentWithCustoms(shippoAddress(shipment.AddressFrom), shippoAddress(shipment.AddressTo), shipment.ShippoCustomsID, shippoParcels(shipment.Parcels)...)
+	} else {
+		rated, err = client.CreateShipment(shippoAddress(shipment.AddressFrom), shippoAddress(shipment.AddressTo), shippoParcels(shipment.Parcels)...)
+	}
+	if err != nil {
+		switch {
+		case shippo.IsRejected(err):
+			app.badRequestResponse(w, r, err)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	shipment.ShippoShipmentID = rated.ID
+	shipment.Transition(data.ShipmentStatusRated)
+
+	err = app.models.Shipments.Update(shipment)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
 	}
 
-	fmt.Printf("response %s", res)
+	env := envelope{"shipment": shipment, "rates": rated.Rates}
+	if len(rated.Messages) > 0 {
+		env["messages"] = rated.Messages
+	}
 
-	err = app.writeJSON(w, 200, envelope{"rates": res}, nil)
+	err = app.writeJSON(w, http.StatusOK, env, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 }
+
+func shippoAddress(address data.Address) shippo.Address {
+	return shippo.Address{
+		Name:    address.Name,
+		Company: address.Company,
+		Street1: address.Street1,
+		Street2: address.Street2,
+		City:    address.City,
+		State:   address.State,
+		Zip:     address.Zip,
+		Country: address.Country,
+		Phone:   address.Phone,
+		Email:   address.Email,
+	}
+}
+
+func shippoParcels(parcels []data.Parcel) []shippo.Parcel {
+	out := make([]shippo.Parcel, len(parcels))
+
+	for i, parcel := range parcels {
+		out[i] = shippo.Parcel{
+			Length: parcel.Length,
+			Width:  parcel.Width,
+			Height: parcel.Height,
+			Weight: parcel.Weight,
+		}
+	}
+
+	return out
+}
+
+func shippoCustoms(declaration *customs.Declaration) shippo.CustomsDeclaration {
+	out := shippo.CustomsDeclaration{
+		ContentsType:        declaration.ContentsType,
+		ContentsExplanation: declaration.ContentsExplanation,
+		NonDeliveryOption:   declaration.NonDeliveryOption,
+		CertifySigner:       declaration.CertifySigner,
+		Incoterm:            declaration.Incoterm,
+		EELPFC:              declaration.EELPFC,
+		AESITN:              declaration.AESITN,
+		Items:               make([]shippo.CustomsItem, len(declaration.Items)),
+	}
+
+	for i, item := range declaration.Items {
+		out.Items[i] = shippo.CustomsItem{
+			Description:   item.Description,
+			TariffNumber:  item.TariffNumber,
+			OriginCountry: item.OriginCountry,
+			Quantity:      item.Quantity,
+			NetWeight:     item.NetWeight,
+			Value:         item.Value,
+			Currency:      declaration.Currency,
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/pistolricks/ShippingApi/internal/delivery"
+	"github.com/pistolricks/ShippingApi/internal/shopify"
+	uspsApi "github.com/pistolricks/ShippingApi/internal/usps"
+)
+
+// handleShopifyRates answers Shopify's Carrier Calculated Shipping callback.
+// Shopify shows no USPS options at checkout when the response holds no
+// rates, so requests that can't be priced are logged and answered with an
+// empty list rather than an error.
+func (app *application) handleShopifyRates(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, 1_048_576)
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if !shopify.VerifyHMAC(app.config.shopify.secret, body, r.Header.Get(shopify.HMACHeader)) {
+		app.invalidSignatureResponse(w, r)
+		return
+	}
+
+	var input shopify.RateRequest
+
+	err = json.Unmarshal(body, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, errors.New("body contains badly-formed JSON"))
+		return
+	}
+
+	rates := []shopify.Rate{}
+
+	items := input.ShippedItems()
+	if len(items) == 0 {
+		app.writeShopifyRates(w, r, rates)
+		return
+	}
+
+	quotes, err := app.storefrontQuotes(r.Context(), rateInput{
+		OriginZIPCode:          input.Rate.Origin.ZIPCode(),
+		DestinationZIPCode:     input.Rate.Destination.ZIPCode(),
+		DestinationCountryCode: input.Rate.Destination.Country,
+		ForeignPostalCode:      input.Rate.Destination.PostalCode,
+		Weight:                 math.Ceil(shopify.Ounces(items)*100) / 100,
+		Items:                  shopifyRateItems(items),
+	})
+	if err != nil {
+		app.logError(r, err)
+		app.writeShopifyRates(w, r, rates)
+		return
+	}
+
+	for _, quote := range quotes {
+		rate := shopify.Rate{
+			ServiceName: uspsApi.MailClassName(quote.MailClass),
+			ServiceCode: quote.MailClass,
+			TotalPrice:  fmt.Sprintf("%d", int64(math.Round(quote.Price*100))),
+			Description: quote.Description,
+			Currency:    quote.Currency,
+		}
+
+		if quote.Delivery != nil {
+			rate.MinDeliveryDate = app.shopifyDeliveryDate(quote.Delivery.Earliest)
+			rate.MaxDeliveryDate = app.shopifyDeliveryDate(quote.Delivery.Latest)
+		}
+
+		rates = append(rates, rate)
+	}
+
+	app.writeShopifyRates(w, r, rates)
+}
+
+func (app *application) writeShopifyRates(w http.ResponseWriter, r *http.Request, rates []shopify.Rate) {
+	err := app.writeJSON(w, http.StatusOK, envelope{"rates": rates}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// shopifyDeliveryDate converts a delivery window date to the timestamp
+// Shopify expects, at midnight in the warehouse time zone.
+func (app *application) shopifyDeliveryDate(date string) string {
+	t, err := time.ParseInLocation(delivery.DateLayout, date, app.estimator.Location)
+	if err != nil {
+		return ""
+	}
+
+	return t.Format(shopify.DeliveryDateLayout)
+}
+
+// shopifyRateItems lists the cart items by SKU, so that they can be packed
+// using their catalog dimensions.
+func shopifyRateItems(items []shopify.Item) []rateItem {
+	out := make([]rateItem, len(items))
+
+	for i, item := range items {
+		out[i] = rateItem{SKU: item.SKU, Qty: item.Quantity}
+	}
+
+	return out
+}
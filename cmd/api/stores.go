@@ -0,0 +1,175 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/pistolricks/ShippingApi/internal/data"
+	"github.com/pistolricks/ShippingApi/internal/validator"
+)
+
+func (app *application) handleCreateStore(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name            string `json:"name"`
+		Platform        string `json:"platform"`
+		OriginZIPCode   string `json:"origin_zip_code"`
+		NotifyCustomers *bool  `json:"notify_customers"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	store := &data.Store{
+		Name:            input.Name,
+		Platform:        input.Platform,
+		OriginZIPCode:   input.OriginZIPCode,
+		NotifyCustomers: true,
+	}
+
+	if input.NotifyCustomers != nil {
+		store.NotifyCustomers = *input.NotifyCustomers
+	}
+
+	v := validator.New()
+
+	if data.ValidateStore(v, store); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Stores.Insert(store)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/api/v1/stores/%d", store.ID))
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"store": store}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) handleListStores(w http.ResponseWriter, r *http.Request) {
+	stores, err := app.models.Stores.GetAll()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"stores": stores}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) handleUpdateStore(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	store, err := app.models.Stores.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		Name            *string `json:"name"`
+		OriginZIPCode   *string `json:"origin_zip_code"`
+		NotifyCustomers *bool   `json:"notify_customers"`
+		Version         *int    `json:"version"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Version != nil && *input.Version != store.Version {
+		app.editConflictResponse(w, r)
+		return
+	}
+
+	if input.Name != nil {
+		store.Name = *input.Name
+	}
+	if input.OriginZIPCode != nil {
+		store.OriginZIPCode = *input.OriginZIPCode
+	}
+	if input.NotifyCustomers != nil {
+		store.NotifyCustomers = *input.NotifyCustomers
+	}
+
+	v := validator.New()
+
+	if data.ValidateStore(v, store); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Stores.Update(store)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"store": store}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) handleRotateStoreKey(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	store, err := app.models.Stores.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.models.Stores.RotateKey(store)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"store": store}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -101,22 +101,22 @@ func (app *application) createPasswordResetTokenHandler(w http.ResponseWriter, r
 		return
 	}
 
-	token, err := app.models.Tokens.New(user.ID, 45*time.Minute, data.ScopePasswordReset)
-	if err != nil {
-		app.serverErrorResponse(w, r, err)
-		return
-	}
+	err = app.models.Transaction(func(tx data.TxModels) error {
+		token, err := tx.Tokens.New(user.ID, 45*time.Minute, data.ScopePasswordReset)
+		if err != nil {
+			return err
+		}
 
-	app.background(func() {
-		data := map[string]any{
+		input := map[string]any{
 			"passwordResetToken": token.Plaintext,
 		}
 
-		err := app.mailer.Send(user.Email, "token_password_reset.tmpl", data)
-		if err != nil {
-			app.logger.Error(err.Error())
-		}
+		return queueMail(tx.Outbox, user.Email, "token_password_reset.tmpl", input)
 	})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
 
 	env := envelope{"message": "an email will be sent to you containing password reset instructions"}
 
@@ -162,22 +162,22 @@ func (app *application) createActivationTokenHandler(w http.ResponseWriter, r *h
 		return
 	}
 
-	token, err := app.models.Tokens.New(user.ID, 3*24*time.Hour, data.ScopeActivation)
-	if err != nil {
-		app.serverErrorResponse(w, r, err)
-		return
-	}
+	err = app.models.Transaction(func(tx data.TxModels) error {
+		token, err := tx.Tokens.New(user.ID, 3*24*time.Hour, data.ScopeActivation)
+		if err != nil {
+			return err
+		}
 
-	app.background(func() {
-		data := map[string]any{
+		input := map[string]any{
 			"activationToken": token.Plaintext,
 		}
 
-		err := app.mailer.Send(user.Email, "token_activation.tmpl", data)
-		if err != nil {
-			app.logger.Error(err.Error())
-		}
+		return queueMail(tx.Outbox, user.Email, "token_activation.tmpl", input)
 	})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
 
 	env := envelope{"message": "an email will be sent to you containing activation instructions"}
 
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pistolricks/ShippingApi/internal/data"
+	"github.com/pistolricks/ShippingApi/internal/notify"
+	"github.com/pistolricks/ShippingApi/internal/tracking"
+	uspsApi "github.com/pistolricks/ShippingApi/internal/usps"
+)
+
+func (app *application) handleShowShipmentTracking(w http.ResponseWriter, r *http.Request) {
+	app.shipmentTrackingResponse(w, r, false)
+}
+
+// handleRefreshShipmentTracking polls the carrier now instead of waiting for
+// the background poller, then responds like handleShowShipmentTracking.
+func (app *application) handleRefreshShipmentTracking(w http.ResponseWriter, r *http.Request) {
+	app.shipmentTrackingResponse(w, r, true)
+}
+
+// shipmentTrackingResponse writes the tracking history of the shipment in
+// the URL, refreshing it from the carrier first when refresh is set.
+func (app *application) shipmentTrackingResponse(w http.ResponseWriter, r *http.Request, refresh bool) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	shipment, err := app.models.Shipments.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	out := envelope{
+		"shipment_id": shipment.ID,
+		"status":      tracking.StatusUnknown,
+		"events":      []*data.TrackingEvent{},
+	}
+
+	if shipment.LabelID == nil {
+		err = app.writeJSON(w, http.StatusOK, envelope{"tracking": out}, nil)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	label, err := app.models.Labels.Get(*shipment.LabelID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if refresh {
+		err = app.trackShipment(r.Context(), shipment, label)
+		if err != nil {
+			switch {
+			case errors.Is(err, uspsApi.ErrTrackingNotFound):
+				// Nothing is tracked yet, so the stored history is returned.
+			case errors.Is(err, uspsApi.ErrUpstreamUnavailable):
+				app.serviceUnavailableResponse(w, r, err)
+				return
+			case errors.Is(err, uspsApi.ErrAuthFailed), errors.Is(err, uspsApi.ErrRequestRejected):
+				app.badGatewayResponse(w, r, err)
+				return
+			default:
+				app.serverErrorResponse(w, r, err)
+				return
+			}
+		}
+	}
+
+	events, err := app.models.Tracking.GetForShipment(shipment.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	notifications, err := app.models.Notifications.GetForShipment(shipment.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	out["carrier"] = label.Carrier
+	out["tracking_number"] = label.TrackingNumber
+	out["tracking_url"] = label.TrackingURL
+	out["events"] = events
+	out["notifications"] = notifications
+	if len(events) > 0 {
+		out["status"] = events[0].Status
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"tracking": out}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// trackShipment fetches the tracking history of the shipment's label, stores
+// the new events, moves the shipment along as the package progresses and
+// lets the customer know. Failed attempts still mark the shipment as
+// tracked.
+func (app *application) trackShipment(ctx context.Context, shipment *data.Shipment, label *data.Label) error {
+	if label.TrackingNumber == "" {
+		return nil
+	}
+
+	result, err := app.fetchTracking(ctx, label)
+	if err != nil {
+		// Still count the attempt, so that a shipment the carrier can't
+		// track doesn't hold up the rest of the poller's batch.
+		if markErr := app.models.Shipments.MarkTracked(shipment.ID); markErr != nil {
+			app.logger.Error(markErr.Error(), "shipment_id", shipment.ID)
+		}
+		return err
+	}
+
+	events := trackingEvents(result)
+
+	_, err = app.models.Tracking.Record(shipment.ID, events)
+	if err != nil {
+		return err
+	}
+
+	if advanceShipment(shipment, result.Status) {
+		err = app.models.Shipments.Update(shipment)
+		if errors.Is(err, data.ErrEditConflict) {
+			// The poller and a manual refresh can advance the same shipment
+			// at once. Reload it and only update again if the other writer
+			// didn't already apply the status.
+			err = app.readvanceShipment(shipment, result.Status)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	app.notifyCustomer(shipment, notify.KindForStatus(result.Status))
+
+	return nil
+}
+
+// fetchTracking fetches the tracking history of a label. USPS labels are
+// tracked through the USPS Tracking API, others through Shippo.
+func (app *application) fetchTracking(ctx context.Context, label *data.Label) (*tracking.Result, error) {
+	if strings.EqualFold(label.Carrier, "USPS") {
+		res, err := app.trackingClient.Track(ctx, label.TrackingNumber)
+		if err != nil {
+			return nil, err
+		}
+		return res.Result(), nil
+	}
+
+	client, err := app.shippoClient()
+	if err != nil {
+		return nil, err
+	}
+
+	return client.Track(label.Carrier, label.TrackingNumber)
+}
+
+// readvanceShipment reloads a shipment whose update conflicted and advances
+// the fresh copy to status, saving it only when it isn't there already.
+func (app *application) readvanceShipment(shipment *data.Shipment, status string) error {
+	fresh, err := app.models.Shipments.Get(shipment.ID)
+	if err != nil {
+		return err
+	}
+
+	if advanceShipment(fresh, status) {
+		err = app.models.Shipments.Update(fresh)
+		if err != nil {
+			return err
+		}
+	}
+
+	*shipment = *fresh
+	return nil
+}
+
+// trackingEvents converts a normalized tracking result into events to store.
+func trackingEvents(result *tracking.Result) []*data.TrackingEvent {
+	events := make([]*data.TrackingEvent, len(result.Events))
+	for i, event := range result.Events {
+		events[i] = &data.TrackingEvent{
+			Status:        event.Status,
+			Description:   event.Description,
+			City:          event.City,
+			State:         event.State,
+			Zip:           event.Zip,
+			Country:       event.Country,
+			OccurredAt:    event.OccurredAt,
+			CarrierStatus: event.CarrierStatus,
+		}
+	}
+
+	return events
+}
+
+// advanceShipment moves a labeled shipment to in transit once the carrier
+// has it, and on to delivered or returned, reporting whether the status
+// changed.
+func advanceShipment(shipment *data.Shipment, status string) bool {
+	var target string
+
+	switch status {
+	case tracking.StatusInTransit, tracking.StatusOutForDelivery, tracking.StatusAvailableForPickup, tracking.StatusFailure:
+		target = data.ShipmentStatusInTransit
+	case tracking.StatusDelivered:
+		target = data.ShipmentStatusDelivered
+	case tracking.StatusReturnToSender:
+		target = data.ShipmentStatusReturned
+	default:
+		return false
+	}
+
+	from := shipment.Status
+
+	// A package scanned as delivered on its first poll still passes through
+	// in transit, the only way out of labeled.
+	if from == data.ShipmentStatusLabeled && target != data.ShipmentStatusInTransit {
+		if shipment.Transition(data.ShipmentStatusInTransit) != nil {
+			return false
+		}
+	}
+
+	if shipment.Status != target {
+		_ = shipment.Transition(target)
+	}
+
+	return shipment.Status != from
+}
+
+// pollTracking refreshes the tracking of a batch of shipments in transit
+// that haven't been tracked for -tracking-interval.
+func (app *application) pollTracking(ctx context.Context) {
+	interval, batchSize := app.config.tracking.interval, app.config.tracking.batchSize
+
+	shipments, err := app.models.Shipments.GetDueForTracking(interval, batchSize)
+	if err != nil {
+		app.logger.Error(err.Error())
+		return
+	}
+
+	for _, shipment := range shipments {
+		if ctx.Err() != nil {
+			return
+		}
+
+		label, err := app.models.Labels.Get(*shipment.LabelID)
+		if err != nil {
+			app.logger.Error(err.Error(), "shipment_id", shipment.ID)
+			continue
+		}
+
+		trackCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		err = app.trackShipment(trackCtx, shipment, label)
+		cancel()
+
+		if err != nil && !errors.Is(err, uspsApi.ErrTrackingNotFound) {
+			app.logger.Error(err.Error(), "shipment_id", shipment.ID, "tracking_number", label.TrackingNumber)
+		}
+	}
+
+	if len(shipments) > 0 {
+		app.logger.Info("polled shipment tracking", "count", len(shipments))
+	}
+}
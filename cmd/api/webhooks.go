@@ -0,0 +1,150 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pistolricks/ShippingApi/internal/data"
+	"github.com/pistolricks/ShippingApi/internal/notify"
+	"github.com/pistolricks/ShippingApi/internal/shippo"
+)
+
+// webhookRetries is how many times a tracking update is applied before it
+// is given up, when the shipment keeps changing underneath it.
+const webhookRetries = 3
+
+// handleShippoWebhook receives Shippo track_updated and transaction_created
+// events. Shippo expects an answer within seconds and retries otherwise, so
+// the delivery is only verified and parsed here; applying it happens in the
+// background.
+func (app *application) handleShippoWebhook(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, 1_048_576)
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if !shippo.VerifySignature(app.config.shippo.webhookSecret, body, r.Header.Get(shippo.SignatureHeader), time.Now()) {
+		app.invalidSignatureResponse(w, r)
+		return
+	}
+
+	webhook, err := shippo.ParseWebhook(body)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	switch webhook.Event {
+	case shippo.EventTrackUpdated:
+		update, err := webhook.TrackUpdate()
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+
+		app.background(func() {
+			app.applyShippoTrackUpdate(webhook, update)
+		})
+	case shippo.EventTransactionCreated:
+		update, err := webhook.TransactionUpdate()
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+
+		app.background(func() {
+			app.applyShippoTransactionUpdate(webhook, update)
+		})
+	}
+
+	err = app.writeJSON(w, http.StatusAccepted, envelope{"message": "webhook accepted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// applyShippoTrackUpdate stores the events of a track_updated webhook and
+// moves the shipment along. Updates for packages this service did not ship
+// are ignored.
+func (app *application) applyShippoTrackUpdate(webhook *shippo.Webhook, update *shippo.TrackUpdate) {
+	event := &data.WebhookEvent{
+		Provider:  "shippo",
+		EventID:   webhook.ID,
+		EventType: webhook.Event,
+	}
+
+	events := trackingEvents(update.Result)
+
+	for range webhookRetries {
+		shipment, err := app.models.Shipments.GetByTrackingNumber(update.TrackingNumber)
+		if err != nil {
+			if !errors.Is(err, data.ErrRecordNotFound) {
+				app.logger.Error(err.Error(), "event_id", webhook.ID)
+			}
+			return
+		}
+
+		changed := advanceShipment(shipment, update.Result.Status)
+
+		_, err = app.models.Webhooks.ApplyTrackingUpdate(event, shipment, events, changed)
+		switch {
+		case err == nil:
+			app.notifyCustomer(shipment, notify.KindForStatus(update.Result.Status))
+			return
+		case errors.Is(err, data.ErrDuplicateWebhookEvent):
+			return
+		case errors.Is(err, data.ErrEditConflict):
+			continue
+		default:
+			app.logger.Error(err.Error(), "event_id", webhook.ID, "shipment_id", shipment.ID)
+			return
+		}
+	}
+
+	app.logger.Error("shipment kept changing while applying a tracking webhook", "event_id", webhook.ID, "tracking_number", update.TrackingNumber)
+}
+
+// applyShippoTransactionUpdate fills in the tracking number and URLs of a
+// label from a transaction_created webhook, for carriers that assign them
+// after the purchase returns.
+func (app *application) applyShippoTransactionUpdate(webhook *shippo.Webhook, update *shippo.TransactionUpdate) {
+	if !update.Succeeded {
+		app.logger.Error("shippo transaction failed", "transaction_id", update.ID, "messages", strings.Join(update.Messages, "; "))
+		return
+	}
+
+	label, err := app.models.Labels.GetByTransactionID(update.ID)
+	if err != nil {
+		if !errors.Is(err, data.ErrRecordNotFound) {
+			app.logger.Error(err.Error(), "event_id", webhook.ID)
+		}
+		return
+	}
+
+	if update.TrackingNumber != "" {
+		label.TrackingNumber = update.TrackingNumber
+	}
+	if update.TrackingURL != "" {
+		label.TrackingURL = update.TrackingURL
+	}
+	if update.LabelURL != "" {
+		label.LabelURL = update.LabelURL
+	}
+
+	event := &data.WebhookEvent{
+		Provider:  "shippo",
+		EventID:   webhook.ID,
+		EventType: webhook.Event,
+	}
+
+	err = app.models.Webhooks.ApplyLabelUpdate(event, label)
+	if err != nil && !errors.Is(err, data.ErrDuplicateWebhookEvent) {
+		app.logger.Error(err.Error(), "event_id", webhook.ID, "label_id", label.ID)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+
+	uspsApi "github.com/pistolricks/ShippingApi/internal/usps"
+	"github.com/pistolricks/ShippingApi/internal/woocommerce"
+)
+
+// handleWooCommerceRates prices a WooCommerce cart package for the store
+// owning the request's API key, shipping from the store's origin ZIP code.
+// Like the Shopify callback, packages that can't be priced get an empty rate
+// list so the checkout falls back to the store's other shipping methods.
+func (app *application) handleWooCommerceRates(w http.ResponseWriter, r *http.Request) {
+	store := app.contextGetStore(r)
+
+	var input struct {
+		Package woocommerce.Package `json:"package"`
+	}
+
+	// WooCommerce packages carry many fields this endpoint has no use for,
+	// such as coupons and the customer, so unknown fields are allowed here.
+	r.Body = http.MaxBytesReader(w, r.Body, 1_048_576)
+
+	err := json.NewDecoder(r.Body).Decode(&input)
+	if err != nil {
+		app.badRequestResponse(w, r, errors.New("body contains badly-formed JSON"))
+		return
+	}
+
+	pkg := input.Package
+
+	weight, err := pkg.Ounces()
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	length, width, height, err := pkg.Dimensions()
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	rates := []woocommerce.Rate{}
+
+	if weight <= 0 {
+		app.writeWooCommerceRates(w, r, rates)
+		return
+	}
+
+	quotes, err := app.storefrontQuotes(r.Context(), rateInput{
+		OriginZIPCode:          store.OriginZIPCode,
+		DestinationZIPCode:     pkg.Destination.ZIPCode(),
+		DestinationCountryCode: pkg.Destination.Country,
+		ForeignPostalCode:      pkg.Destination.Postcode,
+		Weight:                 math.Ceil(weight*100) / 100,
+		Length:                 length,
+		Width:                  width,
+		Height:                 height,
+		Items:                  wooCommerceRateItems(pkg.Contents),
+	})
+	if err != nil {
+		app.logError(r, err)
+		app.writeWooCommerceRates(w, r, rates)
+		return
+	}
+
+	for _, quote := range quotes {
+		rate := woocommerce.Rate{
+			ID:      "usps:" + quote.MailClass,
+			Label:   uspsApi.MailClassName(quote.MailClass),
+			Cost:    fmt.Sprintf("%.2f", quote.Price),
+			CalcTax: "per_order",
+		}
+
+		if quote.Delivery != nil {
+			rate.MetaData = map[string]string{
+				"delivery_days":     fmt.Sprintf("%d-%d", quote.Delivery.MinDays, quote.Delivery.MaxDays),
+				"delivery_earliest": quote.Delivery.Earliest,
+				"delivery_latest":   quote.Delivery.Latest,
+			}
+		}
+
+		rates = append(rates, rate)
+	}
+
+	app.writeWooCommerceRates(w, r, rates)
+}
+
+// wooCommerceRateItems lists the package items by SKU, so that they can be
+// packed using their catalog dimensions.
+func wooCommerceRateItems(contents woocommerce.Contents) []rateItem {
+	out := make([]rateItem, len(contents))
+
+	for i, item := range contents {
+		out[i] = rateItem{SKU: item.SKU, Qty: item.Quantity}
+	}
+
+	return out
+}
+
+func (app *application) writeWooCommerceRates(w http.ResponseWriter, r *http.Request, rates []woocommerce.Rate) {
+	err := app.writeJSON(w, http.StatusOK, envelope{"rates": rates}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/pistolricks/ShippingApi/internal/usps/uspsfake"
+)
+
+func main() {
+	port := flag.Int("port", 4091, "Fake USPS server port")
+	fixturesPath := flag.String("fixtures", "", "JSON file of scripted USPS responses and faults")
+
+	flag.Parse()
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	var fixtures uspsfake.Fixtures
+
+	if *fixturesPath != "" {
+		var err error
+
+		fixtures, err = uspsfake.LoadFixtures(*fixturesPath)
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+	}
+
+	srv := &http.Server{
+		Addr:         fmt.Sprintf(":%d", *port),
+		Handler:      uspsfake.New(fixtures),
+		IdleTimeout:  time.Minute,
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 10 * time.Second,
+		ErrorLog:     slog.NewLogLogger(logger.Handler(), slog.LevelError),
+	}
+
+	logger.Info("starting fake USPS server", "addr", srv.Addr, "fixtures", *fixturesPath)
+
+	err := srv.ListenAndServe()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+}
@@ -1,6 +1,6 @@
 module github.com/pistolricks/ShippingApi
 
-go 1.24.7
+go 1.25.0
 
 require (
 	github.com/coldbrewcloud/go-shippo v1.6.0
@@ -0,0 +1,287 @@
+package customs
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+
+	"github.com/pistolricks/ShippingApi/internal/validator"
+)
+
+// Contents types accepted by Shippo customs declarations.
+const (
+	ContentsDocuments            = "DOCUMENTS"
+	ContentsGift                 = "GIFT"
+	ContentsSample               = "SAMPLE"
+	ContentsMerchandise          = "MERCHANDISE"
+	ContentsHumanitarianDonation = "HUMANITARIAN_DONATION"
+	ContentsReturnMerchandise    = "RETURN_MERCHANDISE"
+	ContentsOther                = "OTHER"
+)
+
+// What the carrier should do with a parcel it cannot deliver.
+const (
+	NonDeliveryReturn  = "RETURN"
+	NonDeliveryAbandon = "ABANDON"
+)
+
+// Export exemptions (EEL/PFC codes). EELNoEEI3037a covers exports under the
+// $2,500 per tariff number filing threshold; anything above it needs an AES
+// filing and its ITN.
+const (
+	EELNoEEI3037a = "NOEEI_30_37_a"
+	EELNoEEI3037h = "NOEEI_30_37_h"
+	EELNoEEI3037f = "NOEEI_30_37_f"
+	EELNoEEI3036  = "NOEEI_30_36"
+	EELAESITN     = "AES_ITN"
+)
+
+// Incoterms say who pays duties and taxes: DDP bills the shipper, DDU the
+// recipient.
+const (
+	IncotermDDP = "DDP"
+	IncotermDDU = "DDU"
+)
+
+var (
+	ContentsTypes = []string{
+		ContentsDocuments,
+		ContentsGift,
+		ContentsSample,
+		ContentsMerchandise,
+		ContentsHumanitarianDonation,
+		ContentsReturnMerchandise,
+		ContentsOther,
+	}
+
+	NonDeliveryOptions = []string{NonDeliveryReturn, NonDeliveryAbandon}
+
+	EELPFCs = []string{EELNoEEI3037a, EELNoEEI3037h, EELNoEEI3037f, EELNoEEI3036, EELAESITN}
+
+	Incoterms = []string{IncotermDDP, IncotermDDU}
+)
+
+// AESThreshold is the value, in US dollars, above which the goods declared
+// under a single tariff number must be filed with AES before export.
+const AESThreshold = 2500
+
+// MaxItems caps the number of distinct items on one declaration.
+const MaxItems = 100
+
+var (
+	// TariffNumberRX matches a Harmonized System code without dots: the 6
+	// digit HS subheading, optionally extended to 8 or 10 digits.
+	TariffNumberRX = regexp.MustCompile(`^\d{6}(\d{2}){0,2}$`)
+	CurrencyRX     = regexp.MustCompile(`^[A-Z]{3}$`)
+	ITNRX          = regexp.MustCompile(`^X\d{14}$`)
+)
+
+// LineItem is an order line to be declared. UnitValue and UnitWeight are per
+// unit, in Currency and ounces.
+type LineItem struct {
+	SKU           string  `json:"sku,omitempty"`
+	Description   string  `json:"description"`
+	TariffNumber  string  `json:"tariff_number"`
+	OriginCountry string  `json:"origin_country"`
+	Quantity      int     `json:"quantity"`
+	UnitValue     float64 `json:"unit_value"`
+	UnitWeight    float64 `json:"unit_weight"`
+}
+
+// Item is one line of a customs declaration. NetWeight and Value are totals
+// for the whole quantity.
+type Item struct {
+	Description   string   `json:"description"`
+	TariffNumber  string   `json:"tariff_number,omitempty"`
+	OriginCountry string   `json:"origin_country"`
+	Quantity      int      `json:"quantity"`
+	NetWeight     float64  `json:"net_weight"`
+	Value         float64  `json:"value"`
+	SKUs          []string `json:"skus,omitempty"`
+}
+
+// Declaration is the customs declaration attached to an international
+// shipment.
+type Declaration struct {
+	ContentsType        string  `json:"contents_type"`
+	ContentsExplanation string  `json:"contents_explanation,omitempty"`
+	NonDeliveryOption   string  `json:"non_delivery_option"`
+	CertifySigner       string  `json:"certify_signer"`
+	Incoterm            string  `json:"incoterm,omitempty"`
+	EELPFC              string  `json:"eel_pfc"`
+	AESITN              string  `json:"aes_itn,omitempty"`
+	Currency            string  `json:"currency"`
+	Items               []Item  `json:"items"`
+	TotalValue          float64 `json:"total_value"`
+	TotalWeight         float64 `json:"total_weight"`
+}
+
+// Build fills in d from order line items. Lines describing the same goods
+// (same description, tariff number and origin) are merged into one item, so
+// an order with the same product on several lines is declared once. Unset
+// options default to returning undeliverable parcels in US dollars under the
+// low-value export exemption.
+func Build(d Declaration, lines []LineItem) *Declaration {
+	d.ContentsType = strings.ToUpper(strings.TrimSpace(d.ContentsType))
+	d.NonDeliveryOption = strings.ToUpper(strings.TrimSpace(d.NonDeliveryOption))
+	d.Incoterm = strings.ToUpper(strings.TrimSpace(d.Incoterm))
+	d.Currency = strings.ToUpper(strings.TrimSpace(d.Currency))
+
+	if d.NonDeliveryOption == "" {
+		d.NonDeliveryOption = NonDeliveryReturn
+	}
+	if d.Currency == "" {
+		d.Currency = "USD"
+	}
+	if d.EELPFC == "" {
+		d.EELPFC = EELNoEEI3037a
+	}
+
+	d.Items = nil
+	d.TotalValue, d.TotalWeight = 0, 0
+
+	index := make(map[string]int)
+
+	for _, line := range lines {
+		item := Item{
+			Description:   strings.TrimSpace(line.Description),
+			TariffNumber:  strings.ReplaceAll(strings.TrimSpace(line.TariffNumber), ".", ""),
+			OriginCountry: strings.ToUpper(strings.TrimSpace(line.OriginCountry)),
+			Quantity:      line.Quantity,
+			NetWeight:     line.UnitWeight * float64(line.Quantity),
+			Value:         round(line.UnitValue * float64(line.Quantity)),
+		}
+		if line.SKU != "" {
+			item.SKUs = []string{line.SKU}
+		}
+
+		key := strings.ToLower(item.Description) + "|" + item.TariffNumber + "|" + item.OriginCountry
+
+		if i, ok := index[key]; ok {
+			merged := &d.Items[i]
+			merged.Quantity += item.Quantity
+			merged.NetWeight += item.NetWeight
+			merged.Value = round(merged.Value + item.Value)
+			for _, sku := range item.SKUs {
+				if !validator.PermittedValue(sku, merged.SKUs...) {
+					merged.SKUs = append(merged.SKUs, sku)
+				}
+			}
+			continue
+		}
+
+		index[key] = len(d.Items)
+		d.Items = append(d.Items, item)
+	}
+
+	for _, item := range d.Items {
+		d.TotalValue += item.Value
+		d.TotalWeight += item.NetWeight
+	}
+	d.TotalValue = round(d.TotalValue)
+
+	return &d
+}
+
+// ValuesByTariffNumber totals the declared value of each tariff number, the
+// basis for the AES filing threshold.
+func (d *Declaration) ValuesByTariffNumber() map[string]float64 {
+	values := make(map[string]float64)
+
+	for _, item := range d.Items {
+		if item.TariffNumber != "" {
+			values[item.TariffNumber] = round(values[item.TariffNumber] + item.Value)
+		}
+	}
+
+	return values
+}
+
+// RequiresAES reports whether any tariff number on the declaration is over
+// AESThreshold, which rules out the NOEEI exemptions.
+func (d *Declaration) RequiresAES() bool {
+	if d.Currency != "USD" {
+		return false
+	}
+
+	for _, value := range d.ValuesByTariffNumber() {
+		if value > AESThreshold {
+			return true
+		}
+	}
+
+	return false
+}
+
+// commercial reports whether the contents are being sold or returned, which
+// customs requires to be classified by tariff number.
+func commercial(contentsType string) bool {
+	return contentsType == ContentsMerchandise || contentsType == ContentsReturnMerchandise
+}
+
+func ValidateDeclaration(v *validator.Validator, d *Declaration) {
+	v.Check(d.ContentsType != "", "contents_type", "must be provided")
+	v.Check(validator.PermittedValue(d.ContentsType, ContentsTypes...), "contents_type", "invalid contents type")
+
+	if d.ContentsType == ContentsOther {
+		v.Check(strings.TrimSpace(d.ContentsExplanation) != "", "contents_explanation", "must be provided when contents type is OTHER")
+	}
+	v.Check(len(d.ContentsExplanation) <= 200, "contents_explanation", "must not be more than 200 characters")
+
+	v.Check(validator.PermittedValue(d.NonDeliveryOption, NonDeliveryOptions...), "non_delivery_option", "must be RETURN or ABANDON")
+	v.Check(strings.TrimSpace(d.CertifySigner) != "", "certify_signer", "must be provided")
+
+	if d.Incoterm != "" {
+		v.Check(validator.PermittedValue(d.Incoterm, Incoterms...), "incoterm", "must be DDP or DDU")
+	}
+
+	v.Check(validator.Matches(d.Currency, CurrencyRX), "currency", "must be a 3 letter ISO 4217 currency code")
+
+	v.Check(validator.PermittedValue(d.EELPFC, EELPFCs...), "eel_pfc", "invalid export exemption")
+	if d.EELPFC == EELAESITN {
+		v.Check(validator.Matches(d.AESITN, ITNRX), "aes_itn", "must be an ITN such as X20240101123456")
+	} else {
+		v.Check(d.AESITN == "", "aes_itn", "must only be provided when eel_pfc is AES_ITN")
+		v.Check(!d.RequiresAES(), "eel_pfc", fmt.Sprintf("must be AES_ITN when a tariff number is valued over $%d", AESThreshold))
+	}
+
+	v.Check(len(d.Items) > 0, "items", "must contain at least 1 item")
+	v.Check(len(d.Items) <= MaxItems, "items", fmt.Sprintf("must not contain more than %d items", MaxItems))
+
+	for i, item := range d.Items {
+		ValidateItem(v, fmt.Sprintf("items[%d]", i), d.ContentsType, item)
+	}
+}
+
+// ValidateItem checks a declared item. Which fields are required depends on
+// the contents type: commercial goods need a tariff number and a value,
+// documents may be declared without value.
+func ValidateItem(v *validator.Validator, key, contentsType string, item Item) {
+	v.Check(item.Description != "", key+".description", "must be provided")
+	v.Check(len(item.Description) <= 50, key+".description", "must not be more than 50 characters")
+
+	v.Check(item.Quantity > 0, key+".quantity", "must be greater than zero")
+	v.Check(item.NetWeight > 0, key+".net_weight", "must be greater than zero")
+
+	v.Check(item.OriginCountry != "", key+".origin_country", "must be provided")
+	v.Check(validator.IsCountryCode(item.OriginCountry), key+".origin_country", "must be an ISO 3166-1 alpha-2 country code")
+
+	if item.TariffNumber != "" {
+		v.Check(validator.Matches(item.TariffNumber, TariffNumberRX), key+".tariff_number", "must be a 6, 8 or 10 digit HS code")
+	}
+
+	v.Check(item.Value >= 0, key+".value", "must not be negative")
+
+	switch {
+	case commercial(contentsType):
+		v.Check(item.TariffNumber != "", key+".tariff_number", "must be provided for "+strings.ToLower(contentsType))
+		v.Check(item.Value > 0, key+".value", "must be greater than zero for "+strings.ToLower(contentsType))
+	case contentsType != ContentsDocuments:
+		v.Check(item.Value > 0, key+".value", "must be greater than zero")
+	}
+}
+
+func round(f float64) float64 {
+	return math.Round(f*100) / 100
+}
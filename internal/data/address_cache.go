@@ -0,0 +1,82 @@
+package data
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// AddressCacheKey hashes the given address fields into a cache key. Fields
+// are uppercased and runs of whitespace collapsed first, so addresses that
+// differ only in case or spacing share a key.
+func AddressCacheKey(fields ...string) []byte {
+	normalized := make([]string, len(fields))
+
+	for i, field := range fields {
+		normalized[i] = strings.Join(strings.Fields(strings.ToUpper(field)), " ")
+	}
+
+	hash := sha256.Sum256([]byte(strings.Join(normalized, "|")))
+	return hash[:]
+}
+
+type AddressCacheModel struct {
+	DB *sql.DB
+}
+
+func (m AddressCacheModel) Get(key []byte) ([]byte, error) {
+	query := `
+        SELECT response
+        FROM address_cache
+        WHERE key = $1 AND expiry > $2`
+
+	var response []byte
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, key, time.Now()).Scan(&response)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return response, nil
+}
+
+func (m AddressCacheModel) Set(key []byte, response []byte, ttl time.Duration) error {
+	query := `
+        INSERT INTO address_cache (key, response, expiry)
+        VALUES ($1, $2, $3)
+        ON CONFLICT (key) DO UPDATE
+        SET response = EXCLUDED.response, created_at = NOW(), expiry = EXCLUDED.expiry`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, key, response, time.Now().Add(ttl))
+	return err
+}
+
+func (m AddressCacheModel) DeleteExpired(ctx context.Context) (int64, error) {
+	query := `
+        DELETE FROM address_cache
+        WHERE expiry <= $1`
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
@@ -0,0 +1,122 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var (
+	ErrDuplicateLabel = errors.New("duplicate label")
+)
+
+type Label struct {
+	ID             int64     `json:"id"`
+	CreatedAt      time.Time `json:"created_at"`
+	TransactionID  string    `json:"transaction_id"`
+	RateID         string    `json:"rate_id"`
+	Carrier        string    `json:"carrier"`
+	ServiceLevel   string    `json:"service_level"`
+	TrackingNumber string    `json:"tracking_number"`
+	TrackingURL    string    `json:"tracking_url"`
+	LabelURL       string    `json:"label_url"`
+	Cost           float64   `json:"cost"`
+	Currency       string    `json:"currency"`
+}
+
+type LabelModel struct {
+	DB *sql.DB
+}
+
+func (m LabelModel) Insert(label *Label) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return insertLabel(ctx, m.DB, label)
+}
+
+func insertLabel(ctx context.Context, db rowQuerier, label *Label) error {
+	query := `
+        INSERT INTO labels (transaction_id, rate_id, carrier, service_level, tracking_number, tracking_url, label_url, cost, currency)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+        RETURNING id, created_at`
+
+	args := []any{
+		label.TransactionID,
+		label.RateID,
+		label.Carrier,
+		label.ServiceLevel,
+		label.TrackingNumber,
+		label.TrackingURL,
+		label.LabelURL,
+		label.Cost,
+		label.Currency,
+	}
+
+	err := db.QueryRowContext(ctx, query, args...).Scan(&label.ID, &label.CreatedAt)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "labels_transaction_id_key"`:
+			return ErrDuplicateLabel
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m LabelModel) Get(id int64) (*Label, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	return m.get(`WHERE id = $1`, id)
+}
+
+// GetByTransactionID returns the label bought in the given Shippo
+// transaction.
+func (m LabelModel) GetByTransactionID(transactionID string) (*Label, error) {
+	if transactionID == "" {
+		return nil, ErrRecordNotFound
+	}
+
+	return m.get(`WHERE transaction_id = $1`, transactionID)
+}
+
+func (m LabelModel) get(where string, arg any) (*Label, error) {
+	query := `
+        SELECT id, created_at, transaction_id, rate_id, carrier, service_level, tracking_number, tracking_url, label_url, cost, currency
+        FROM labels
+        ` + where
+
+	var label Label
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, arg).Scan(
+		&label.ID,
+		&label.CreatedAt,
+		&label.TransactionID,
+		&label.RateID,
+		&label.Carrier,
+		&label.ServiceLevel,
+		&label.TrackingNumber,
+		&label.TrackingURL,
+		&label.LabelURL,
+		&label.Cost,
+		&label.Currency,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &label, nil
+}
@@ -1,6 +1,7 @@
 package data
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 )
@@ -10,16 +11,77 @@ var (
 	ErrEditConflict   = errors.New("edit conflict")
 )
 
+// DBTX is satisfied by both *sql.DB and *sql.Tx, so the models built on it
+// can also run inside a transaction started by Models.Transaction.
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
 type Models struct {
+	AddressCache  AddressCacheModel
+	Labels        LabelModel
+	Notifications NotificationModel
+	Outbox        MailOutboxModel
+	Permissions   PermissionModel
+	Products      ProductModel
+	Shipments     ShipmentModel
+	Stores        StoreModel
+	Tokens        TokenModel
+	Tracking      TrackingEventModel
+	Users         UserModel
+	Webhooks      WebhookEventModel
+
+	db *sql.DB
+}
+
+// TxModels are the models whose writes can share a transaction, such as a
+// new user, their activation token and the email that carries it.
+type TxModels struct {
+	Outbox      MailOutboxModel
 	Permissions PermissionModel
 	Tokens      TokenModel
 	Users       UserModel
 }
 
+// Transaction calls fn with models bound to a new transaction, committing it
+// when fn returns nil and rolling it back otherwise.
+func (m Models) Transaction(fn func(tx TxModels) error) error {
+	tx, err := m.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = fn(TxModels{
+		Outbox:      MailOutboxModel{DB: tx},
+		Permissions: PermissionModel{DB: tx},
+		Tokens:      TokenModel{DB: tx},
+		Users:       UserModel{DB: tx},
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
 func NewModels(db *sql.DB) Models {
 	return Models{
-		Permissions: PermissionModel{DB: db},
-		Tokens:      TokenModel{DB: db},
-		Users:       UserModel{DB: db},
+		AddressCache:  AddressCacheModel{DB: db},
+		Labels:        LabelModel{DB: db},
+		Notifications: NotificationModel{DB: db},
+		Outbox:        MailOutboxModel{DB: db},
+		Permissions:   PermissionModel{DB: db},
+		Products:      ProductModel{DB: db},
+		Shipments:     ShipmentModel{DB: db},
+		Stores:        StoreModel{DB: db},
+		Tokens:        TokenModel{DB: db},
+		Tracking:      TrackingEventModel{DB: db},
+		Users:         UserModel{DB: db},
+		Webhooks:      WebhookEventModel{DB: db},
+
+		db: db,
 	}
 }
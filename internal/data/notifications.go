@@ -0,0 +1,121 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+const (
+	NotificationLabelCreated   = "label_created"
+	NotificationOutForDelivery = "out_for_delivery"
+	NotificationDelivered      = "delivered"
+	NotificationException      = "exception"
+)
+
+var NotificationKinds = []string{
+	NotificationLabelCreated,
+	NotificationOutForDelivery,
+	NotificationDelivered,
+	NotificationException,
+}
+
+var (
+	ErrDuplicateNotification = errors.New("duplicate notification")
+)
+
+// Notification records that a customer has been emailed about a shipment.
+// A shipment gets each kind of notification at most once.
+type Notification struct {
+	ID         int64     `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	ShipmentID int64     `json:"shipment_id"`
+	Kind       string    `json:"kind"`
+	Recipient  string    `json:"recipient"`
+}
+
+type NotificationModel struct {
+	DB *sql.DB
+}
+
+// Claim records the notification before it is sent, returning
+// ErrDuplicateNotification when the shipment already had one of the same
+// kind. Claiming first means two workers racing on the same event can't
+// both send it.
+func (m NotificationModel) Claim(notification *Notification) error {
+	query := `
+        INSERT INTO notifications (shipment_id, kind, recipient)
+        VALUES ($1, $2, $3)
+        ON CONFLICT (shipment_id, kind) DO NOTHING
+        RETURNING id, created_at`
+
+	args := []any{notification.ShipmentID, notification.Kind, notification.Recipient}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&notification.ID, &notification.CreatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrDuplicateNotification
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Release drops a claimed notification whose email could not be sent, so
+// that it is tried again on the next event.
+func (m NotificationModel) Release(notification *Notification) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, `DELETE FROM notifications WHERE id = $1`, notification.ID)
+	return err
+}
+
+func (m NotificationModel) GetForShipment(shipmentID int64) ([]*Notification, error) {
+	query := `
+        SELECT id, created_at, shipment_id, kind, recipient
+        FROM notifications
+        WHERE shipment_id = $1
+        ORDER BY created_at, id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, shipmentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := []*Notification{}
+
+	for rows.Next() {
+		var notification Notification
+
+		err := rows.Scan(
+			&notification.ID,
+			&notification.CreatedAt,
+			&notification.ShipmentID,
+			&notification.Kind,
+			&notification.Recipient,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		notifications = append(notifications, &notification)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return notifications, nil
+}
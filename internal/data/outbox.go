@@ -0,0 +1,275 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	MailStatusPending = "pending"
+	MailStatusSent    = "sent"
+	MailStatusDead    = "dead"
+)
+
+var MailStatuses = []string{
+	MailStatusPending,
+	MailStatusSent,
+	MailStatusDead,
+}
+
+// MailLease is how long a claimed message is hidden from other workers. A
+// worker that dies mid-send leaves the message to be picked up again once
+// the lease runs out.
+const MailLease = 5 * time.Minute
+
+// MailMessage is a rendered email waiting in, or sent from, the outbox.
+type MailMessage struct {
+	ID            int64      `json:"id"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	Status        string     `json:"status"`
+	Recipient     string     `json:"recipient"`
+	Template      string     `json:"template"`
+	Subject       string     `json:"subject"`
+	PlainBody     string     `json:"-"`
+	HTMLBody      string     `json:"-"`
+	Attempts      int        `json:"attempts"`
+	NextAttemptAt time.Time  `json:"next_attempt_at"`
+	LastError     string     `json:"last_error,omitempty"`
+	SentAt        *time.Time `json:"sent_at,omitempty"`
+}
+
+type MailOutboxModel struct {
+	DB DBTX
+}
+
+func (m MailOutboxModel) Insert(message *MailMessage) error {
+	query := `
+        INSERT INTO mail_outbox (recipient, template, subject, plain_body, html_body)
+        VALUES ($1, $2, $3, $4, $5)
+        RETURNING id, created_at, updated_at, status, next_attempt_at`
+
+	args := []any{message.Recipient, message.Template, message.Subject, message.PlainBody, message.HTMLBody}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&message.ID, &message.CreatedAt, &message.UpdatedAt, &message.Status, &message.NextAttemptAt)
+}
+
+const mailColumns = `
+        id, created_at, updated_at, status, recipient, template, subject, plain_body, html_body,
+        attempts, next_attempt_at, last_error, sent_at`
+
+func mailDest(message *MailMessage) []any {
+	return []any{
+		&message.ID,
+		&message.CreatedAt,
+		&message.UpdatedAt,
+		&message.Status,
+		&message.Recipient,
+		&message.Template,
+		&message.Subject,
+		&message.PlainBody,
+		&message.HTMLBody,
+		&message.Attempts,
+		&message.NextAttemptAt,
+		&message.LastError,
+		&message.SentAt,
+	}
+}
+
+func (m MailOutboxModel) Get(id int64) (*MailMessage, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `SELECT` + mailColumns + `
+        FROM mail_outbox
+        WHERE id = $1`
+
+	var message MailMessage
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(mailDest(&message)...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &message, nil
+}
+
+func (m MailOutboxModel) GetAll(status string, filters Filters) ([]*MailMessage, Metadata, error) {
+	query := fmt.Sprintf(`
+        SELECT count(*) OVER(),`+mailColumns+`
+        FROM mail_outbox
+        WHERE (status = $1 OR $1 = '')
+        ORDER BY %s %s, id ASC
+        LIMIT $2 OFFSET $3`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, status, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	messages := []*MailMessage{}
+
+	for rows.Next() {
+		var message MailMessage
+
+		err := rows.Scan(append([]any{&totalRecords}, mailDest(&message)...)...)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		messages = append(messages, &message)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return messages, metadata, nil
+}
+
+// ClaimDue takes up to limit pending messages that are due, counts the
+// attempt and leases them for MailLease. SKIP LOCKED lets several workers
+// claim batches at once without picking the same message.
+func (m MailOutboxModel) ClaimDue(limit int) ([]*MailMessage, error) {
+	query := `
+        UPDATE mail_outbox
+        SET attempts = attempts + 1, next_attempt_at = NOW() + make_interval(secs => $1), updated_at = NOW()
+        WHERE id IN (
+            SELECT id FROM mail_outbox
+            WHERE status = $2 AND next_attempt_at <= NOW()
+            ORDER BY next_attempt_at, id
+            LIMIT $3
+            FOR UPDATE SKIP LOCKED
+        )
+        RETURNING` + mailColumns
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, MailLease.Seconds(), MailStatusPending, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := []*MailMessage{}
+
+	for rows.Next() {
+		var message MailMessage
+
+		err := rows.Scan(mailDest(&message)...)
+		if err != nil {
+			return nil, err
+		}
+
+		messages = append(messages, &message)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+// MarkSent records a delivered message and clears its bodies, which can
+// carry activation and password reset tokens that must not outlive the send.
+func (m MailOutboxModel) MarkSent(message *MailMessage) error {
+	query := `
+        UPDATE mail_outbox
+        SET status = $1, sent_at = NOW(), last_error = '', plain_body = '', html_body = '', updated_at = NOW()
+        WHERE id = $2
+        RETURNING status, sent_at, last_error, plain_body, html_body, updated_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, MailStatusSent, message.ID).Scan(&message.Status, &message.SentAt, &message.LastError, &message.PlainBody, &message.HTMLBody, &message.UpdatedAt)
+}
+
+// DeleteOlderThan deletes sent and dead messages last updated more than age
+// ago, returning how many were deleted. Pending messages are always kept.
+func (m MailOutboxModel) DeleteOlderThan(age time.Duration) (int64, error) {
+	query := `
+        DELETE FROM mail_outbox
+        WHERE status <> $1 AND updated_at < NOW() - make_interval(secs => $2)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, MailStatusPending, age.Seconds())
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// MarkFailed records a failed attempt. The message is retried after
+// retryIn, or moved to the dead status when retryIn is zero.
+func (m MailOutboxModel) MarkFailed(message *MailMessage, sendErr error, retryIn time.Duration) error {
+	status := MailStatusPending
+	if retryIn <= 0 {
+		status = MailStatusDead
+	}
+
+	query := `
+        UPDATE mail_outbox
+        SET status = $1, last_error = $2, next_attempt_at = NOW() + make_interval(secs => $3), updated_at = NOW()
+        WHERE id = $4
+        RETURNING status, last_error, next_attempt_at, updated_at`
+
+	args := []any{status, sendErr.Error(), retryIn.Seconds(), message.ID}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&message.Status, &message.LastError, &message.NextAttemptAt, &message.UpdatedAt)
+}
+
+// Requeue gives a dead message a fresh set of attempts, starting now. It
+// returns ErrEditConflict when the message is no longer dead.
+func (m MailOutboxModel) Requeue(message *MailMessage) error {
+	query := `
+        UPDATE mail_outbox
+        SET status = $1, attempts = 0, next_attempt_at = NOW(), updated_at = NOW()
+        WHERE id = $2 AND status = $3
+        RETURNING status, attempts, next_attempt_at, updated_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, MailStatusPending, message.ID, MailStatusDead).Scan(&message.Status, &message.Attempts, &message.NextAttemptAt, &message.UpdatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
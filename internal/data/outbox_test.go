@@ -0,0 +1,200 @@
+package data
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+// newTestModels connects to the migrated database in GREENLIGHT_TEST_DB_DSN,
+// skipping the test when it isn't set.
+func newTestModels(t *testing.T) (Models, *sql.DB) {
+	t.Helper()
+
+	dsn := os.Getenv("GREENLIGHT_TEST_DB_DSN")
+	if dsn == "" {
+		t.Skip("GREENLIGHT_TEST_DB_DSN is not set")
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	err = db.Ping()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewModels(db), db
+}
+
+func insertTestMail(t *testing.T, models Models, db *sql.DB) *MailMessage {
+	t.Helper()
+
+	message := &MailMessage{
+		Recipient: "alice@example.com",
+		Template:  "token_activation.tmpl",
+		Subject:   "Activate your account",
+		PlainBody: "token ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+		HTMLBody:  "<p>token ABCDEFGHIJKLMNOPQRSTUVWXYZ</p>",
+	}
+
+	err := models.Outbox.Insert(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { db.Exec(`DELETE FROM mail_outbox WHERE id = $1`, message.ID) })
+
+	return message
+}
+
+func TestMailOutboxMarkSent(t *testing.T) {
+	models, db := newTestModels(t)
+
+	message := insertTestMail(t, models, db)
+
+	err := models.Outbox.MarkSent(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := models.Outbox.Get(message.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Status != MailStatusSent || got.SentAt == nil {
+		t.Errorf("got status %q, sent at %v; want sent", got.Status, got.SentAt)
+	}
+	if got.PlainBody != "" || got.HTMLBody != "" {
+		t.Errorf("got bodies %q and %q; want them cleared", got.PlainBody, got.HTMLBody)
+	}
+}
+
+func TestMailOutboxClaimDue(t *testing.T) {
+	models, db := newTestModels(t)
+
+	message := insertTestMail(t, models, db)
+
+	claimed := func() *MailMessage {
+		messages, err := models.Outbox.ClaimDue(1000)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, m := range messages {
+			if m.ID == message.ID {
+				return m
+			}
+		}
+		return nil
+	}
+
+	first := claimed()
+	if first == nil {
+		t.Fatal("message was not claimed")
+	}
+	if first.Attempts != 1 {
+		t.Errorf("got %d attempts; want 1", first.Attempts)
+	}
+
+	if claimed() != nil {
+		t.Error("leased message was claimed again")
+	}
+}
+
+func TestMailOutboxFailAndRequeue(t *testing.T) {
+	models, db := newTestModels(t)
+
+	message := insertTestMail(t, models, db)
+
+	err := models.Outbox.MarkFailed(message, errors.New("connection refused"), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if message.Status != MailStatusPending || message.LastError != "connection refused" {
+		t.Errorf("got status %q, last error %q; want pending with the error", message.Status, message.LastError)
+	}
+
+	err = models.Outbox.MarkFailed(message, errors.New("connection refused"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if message.Status != MailStatusDead {
+		t.Errorf("got status %q; want %q", message.Status, MailStatusDead)
+	}
+
+	err = models.Outbox.Requeue(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if message.Status != MailStatusPending || message.Attempts != 0 {
+		t.Errorf("got status %q with %d attempts; want pending with 0", message.Status, message.Attempts)
+	}
+
+	err = models.Outbox.Requeue(message)
+	if !errors.Is(err, ErrEditConflict) {
+		t.Errorf("got error %v requeueing a pending message; want %v", err, ErrEditConflict)
+	}
+}
+
+func TestMailOutboxDeleteOlderThan(t *testing.T) {
+	models, db := newTestModels(t)
+
+	pending := insertTestMail(t, models, db)
+	sent := insertTestMail(t, models, db)
+
+	err := models.Outbox.MarkSent(sent)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A negative age reaches messages updated just now.
+	_, err = models.Outbox.DeleteOlderThan(-time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = models.Outbox.Get(sent.ID)
+	if !errors.Is(err, ErrRecordNotFound) {
+		t.Errorf("got error %v for the sent message; want %v", err, ErrRecordNotFound)
+	}
+
+	_, err = models.Outbox.Get(pending.ID)
+	if err != nil {
+		t.Errorf("pending message was deleted: %v", err)
+	}
+}
+
+func TestModelsTransactionRollback(t *testing.T) {
+	models, db := newTestModels(t)
+
+	var message MailMessage
+	errFailed := errors.New("failed")
+
+	err := models.Transaction(func(tx TxModels) error {
+		message = MailMessage{Recipient: "alice@example.com", Template: "user_welcome.tmpl", Subject: "Welcome"}
+
+		err := tx.Outbox.Insert(&message)
+		if err != nil {
+			return err
+		}
+
+		t.Cleanup(func() { db.Exec(`DELETE FROM mail_outbox WHERE id = $1`, message.ID) })
+
+		return errFailed
+	})
+	if !errors.Is(err, errFailed) {
+		t.Fatalf("got error %v; want %v", err, errFailed)
+	}
+
+	_, err = models.Outbox.Get(message.ID)
+	if !errors.Is(err, ErrRecordNotFound) {
+		t.Errorf("got error %v for a rolled back message; want %v", err, ErrRecordNotFound)
+	}
+}
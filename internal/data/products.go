@@ -0,0 +1,318 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/pistolricks/ShippingApi/internal/validator"
+)
+
+var ErrDuplicateSKU = errors.New("duplicate sku")
+
+// Product is a catalog entry giving the packed weight (ounces) and
+// dimensions (inches) of one unit of a SKU.
+type Product struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	SKU       string    `json:"sku"`
+	Name      string    `json:"name"`
+	Weight    float64   `json:"weight"`
+	Length    float64   `json:"length"`
+	Width     float64   `json:"width"`
+	Height    float64   `json:"height"`
+	Version   int       `json:"version"`
+}
+
+func ValidateProduct(v *validator.Validator, product *Product) {
+	v.Check(product.SKU != "", "sku", "must be provided")
+	v.Check(len(product.SKU) <= 100, "sku", "must not be more than 100 bytes long")
+
+	v.Check(product.Name != "", "name", "must be provided")
+	v.Check(len(product.Name) <= 500, "name", "must not be more than 500 bytes long")
+
+	v.Check(product.Weight > 0, "weight", "must be greater than zero")
+	v.Check(product.Weight <= 70*16, "weight", "must not be more than 1120 ounces")
+
+	v.Check(product.Length > 0, "length", "must be greater than zero")
+	v.Check(product.Width > 0, "width", "must be greater than zero")
+	v.Check(product.Height > 0, "height", "must be greater than zero")
+	v.Check(product.Length <= 108 && product.Width <= 108 && product.Height <= 108, "dimensions", "must not be more than 108 inches")
+}
+
+type ProductModel struct {
+	DB *sql.DB
+}
+
+func (m ProductModel) Insert(product *Product) error {
+	query := `
+        INSERT INTO products (sku, name, weight, length, width, height)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        RETURNING id, created_at, updated_at, version`
+
+	args := []any{product.SKU, product.Name, product.Weight, product.Length, product.Width, product.Height}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&product.ID, &product.CreatedAt, &product.UpdatedAt, &product.Version)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "products_sku_key"`:
+			return ErrDuplicateSKU
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m ProductModel) Get(id int64) (*Product, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+        SELECT id, created_at, updated_at, sku, name, weight, length, width, height, version
+        FROM products
+        WHERE id = $1`
+
+	var product Product
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(productDest(&product)...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &product, nil
+}
+
+// likeEscaper escapes the LIKE wildcards in user input, so that a search for
+// "50%" only matches "50%" rather than anything containing "50".
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// GetAll returns a page of products, optionally filtered to SKUs or names
+// containing search.
+func (m ProductModel) GetAll(search string, filters Filters) ([]*Product, Metadata, error) {
+	query := fmt.Sprintf(`
+        SELECT count(*) OVER(), id, created_at, updated_at, sku, name, weight, length, width, height, version
+        FROM products
+        WHERE (sku ILIKE '%%' || $1 || '%%' ESCAPE '\' OR name ILIKE '%%' || $1 || '%%' ESCAPE '\')
+        ORDER BY %s %s, id ASC
+        LIMIT $2 OFFSET $3`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, likeEscaper.Replace(search), filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	products := []*Product{}
+
+	for rows.Next() {
+		var product Product
+
+		err := rows.Scan(append([]any{&totalRecords}, productDest(&product)...)...)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		products = append(products, &product)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return products, metadata, nil
+}
+
+// GetForSKUs returns the products for the given SKUs keyed by SKU. SKUs not
+// in the catalog are absent from the map.
+func (m ProductModel) GetForSKUs(skus []string) (map[string]*Product, error) {
+	query := `
+        SELECT id, created_at, updated_at, sku, name, weight, length, width, height, version
+        FROM products
+        WHERE sku = ANY($1)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(skus))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	products := make(map[string]*Product, len(skus))
+
+	for rows.Next() {
+		var product Product
+
+		err := rows.Scan(productDest(&product)...)
+		if err != nil {
+			return nil, err
+		}
+
+		products[product.SKU] = &product
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return products, nil
+}
+
+func (m ProductModel) Update(product *Product) error {
+	query := `
+        UPDATE products
+        SET sku = $1, name = $2, weight = $3, length = $4, width = $5, height = $6, updated_at = NOW(), version = version + 1
+        WHERE id = $7 AND version = $8
+        RETURNING updated_at, version`
+
+	args := []any{
+		product.SKU,
+		product.Name,
+		product.Weight,
+		product.Length,
+		product.Width,
+		product.Height,
+		product.ID,
+		product.Version,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&product.UpdatedAt, &product.Version)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "products_sku_key"`:
+			return ErrDuplicateSKU
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m ProductModel) Delete(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+        DELETE FROM products
+        WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// Upsert inserts the products, replacing the name, weight and dimensions of
+// any SKU already in the catalog. It runs in one transaction so an import
+// either applies completely or not at all, and returns how many products
+// were created and updated.
+func (m ProductModel) Upsert(products []*Product) (created, updated int, err error) {
+	query := `
+        INSERT INTO products (sku, name, weight, length, width, height)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        ON CONFLICT (sku) DO UPDATE
+        SET name = EXCLUDED.name, weight = EXCLUDED.weight, length = EXCLUDED.length,
+            width = EXCLUDED.width, height = EXCLUDED.height, updated_at = NOW(), version = products.version + 1
+        RETURNING id, created_at, updated_at, version, (xmax = 0)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer stmt.Close()
+
+	for _, product := range products {
+		var inserted bool
+
+		args := []any{product.SKU, product.Name, product.Weight, product.Length, product.Width, product.Height}
+
+		err := stmt.QueryRowContext(ctx, args...).Scan(&product.ID, &product.CreatedAt, &product.UpdatedAt, &product.Version, &inserted)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		if inserted {
+			created++
+		} else {
+			updated++
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return created, updated, nil
+}
+
+func productDest(product *Product) []any {
+	return []any{
+		&product.ID,
+		&product.CreatedAt,
+		&product.UpdatedAt,
+		&product.SKU,
+		&product.Name,
+		&product.Weight,
+		&product.Length,
+		&product.Width,
+		&product.Height,
+		&product.Version,
+	}
+}
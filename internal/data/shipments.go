@@ -0,0 +1,649 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/pistolricks/ShippingApi/internal/customs"
+	"github.com/pistolricks/ShippingApi/internal/validator"
+)
+
+const (
+	ShipmentStatusDraft      = "draft"
+	ShipmentStatusRated      = "rated"
+	ShipmentStatusPurchasing = "purchasing"
+	ShipmentStatusLabeled    = "labeled"
+	ShipmentStatusInTransit  = "in_transit"
+	ShipmentStatusDelivered  = "delivered"
+	ShipmentStatusReturned   = "returned"
+	ShipmentStatusVoided     = "voided"
+)
+
+var ShipmentStatuses = []string{
+	ShipmentStatusDraft,
+	ShipmentStatusRated,
+	ShipmentStatusPurchasing,
+	ShipmentStatusLabeled,
+	ShipmentStatusInTransit,
+	ShipmentStatusDelivered,
+	ShipmentStatusReturned,
+	ShipmentStatusVoided,
+}
+
+// shipmentTransitions lists the statuses a shipment may move to from each
+// status. A rated shipment may be rated again when its parcels are re-quoted,
+// and goes back to draft when its customs change.
+// A shipment is purchasing while its label is being bought, which keeps a
+// second purchase from starting; it goes back to rated if the purchase fails,
+// and can be voided if the purchase never finishes.
+var shipmentTransitions = map[string][]string{
+	ShipmentStatusDraft:      {ShipmentStatusRated, ShipmentStatusVoided},
+	ShipmentStatusRated:      {ShipmentStatusDraft, ShipmentStatusRated, ShipmentStatusPurchasing, ShipmentStatusVoided},
+	ShipmentStatusPurchasing: {ShipmentStatusLabeled, ShipmentStatusRated, ShipmentStatusVoided},
+	ShipmentStatusLabeled:    {ShipmentStatusInTransit, ShipmentStatusVoided},
+	ShipmentStatusInTransit:  {ShipmentStatusDelivered, ShipmentStatusReturned},
+}
+
+var (
+	ErrInvalidTransition = errors.New("invalid shipment status transition")
+)
+
+type Address struct {
+	ID      int64  `json:"-"`
+	Name    string `json:"name"`
+	Company string `json:"company,omitempty"`
+	Street1 string `json:"street1"`
+	Street2 string `json:"street2,omitempty"`
+	City    string `json:"city"`
+	State   string `json:"state"`
+	Zip     string `json:"zip"`
+	Country string `json:"country"`
+	Phone   string `json:"phone,omitempty"`
+	Email   string `json:"email,omitempty"`
+}
+
+type Parcel struct {
+	ID     int64   `json:"-"`
+	Length float64 `json:"length"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+	Weight float64 `json:"weight"`
+}
+
+type Shipment struct {
+	ID                  int64                `json:"id"`
+	CreatedAt           time.Time            `json:"created_at"`
+	UpdatedAt           time.Time            `json:"updated_at"`
+	Status              string               `json:"status"`
+	Reference           string               `json:"reference,omitempty"`
+	StoreID             *int64               `json:"store_id,omitempty"`
+	AddressFrom         Address              `json:"address_from"`
+	AddressTo           Address              `json:"address_to"`
+	Parcels             []Parcel             `json:"parcels"`
+	Customs             *customs.Declaration `json:"customs,omitempty"`
+	ShippoShipmentID    string               `json:"shippo_shipment_id,omitempty"`
+	ShippoCustomsID     string               `json:"shippo_customs_declaration_id,omitempty"`
+	ShippoTransactionID string               `json:"shippo_transaction_id,omitempty"`
+	LabelID             *int64               `json:"label_id,omitempty"`
+	Version             int                  `json:"version"`
+}
+
+func (s *Shipment) CanTransition(status string) bool {
+	return slices.Contains(shipmentTransitions[s.Status], status)
+}
+
+func (s *Shipment) Transition(status string) error {
+	if !s.CanTransition(status) {
+		return fmt.Errorf("%w: %s to %s", ErrInvalidTransition, s.Status, status)
+	}
+
+	s.Status = status
+
+	return nil
+}
+
+func ValidateAddress(v *validator.Validator, key string, address Address) {
+	v.Check(address.Name != "", key+".name", "must be provided")
+	v.Check(address.Street1 != "", key+".street1", "must be provided")
+	v.Check(address.City != "", key+".city", "must be provided")
+	v.Check(address.Zip != "", key+".zip", "must be provided")
+	v.Check(address.Country != "", key+".country", "must be provided")
+	v.Check(len(address.Country) == 2, key+".country", "must be a 2 letter country code")
+
+	if strings.EqualFold(address.Country, "US") {
+		v.Check(address.State != "", key+".state", "must be provided")
+		v.Check(validator.Matches(address.Zip, validator.ZIPCodeRX), key+".zip", "must be a 5 digit ZIP code")
+	}
+
+	if address.Email != "" {
+		v.Check(validator.Matches(address.Email, validator.EmailRX), key+".email", "must be a valid email address")
+	}
+}
+
+func ValidateParcel(v *validator.Validator, key string, parcel Parcel) {
+	v.Check(parcel.Length > 0, key+".length", "must be greater than zero")
+	v.Check(parcel.Width > 0, key+".width", "must be greater than zero")
+	v.Check(parcel.Height > 0, key+".height", "must be greater than zero")
+	v.Check(parcel.Weight > 0, key+".weight", "must be greater than zero")
+}
+
+func ValidateShipment(v *validator.Validator, shipment *Shipment) {
+	v.Check(len(shipment.Reference) <= 500, "reference", "must not be more than 500 bytes long")
+
+	ValidateAddress(v, "address_from", shipment.AddressFrom)
+	ValidateAddress(v, "address_to", shipment.AddressTo)
+
+	v.Check(len(shipment.Parcels) > 0, "parcels", "must contain at least 1 parcel")
+	v.Check(len(shipment.Parcels) <= 50, "parcels", "must not contain more than 50 parcels")
+
+	for i, parcel := range shipment.Parcels {
+		ValidateParcel(v, fmt.Sprintf("parcels[%d]", i), parcel)
+	}
+
+	v.Check(validator.PermittedValue(shipment.Status, ShipmentStatuses...), "status", "invalid status")
+}
+
+type ShipmentModel struct {
+	DB *sql.DB
+}
+
+func (m ShipmentModel) Insert(shipment *Shipment) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, address := range []*Address{&shipment.AddressFrom, &shipment.AddressTo} {
+		err = insertAddress(ctx, tx, address)
+		if err != nil {
+			return err
+		}
+	}
+
+	query := `
+        INSERT INTO shipments (status, reference, address_from_id, address_to_id, shippo_shipment_id, customs, store_id)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+        RETURNING id, created_at, updated_at, version`
+
+	args := []any{
+		shipment.Status,
+		shipment.Reference,
+		shipment.AddressFrom.ID,
+		shipment.AddressTo.ID,
+		shipment.ShippoShipmentID,
+		customsColumn{&shipment.Customs},
+		shipment.StoreID,
+	}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&shipment.ID, &shipment.CreatedAt, &shipment.UpdatedAt, &shipment.Version)
+	if err != nil {
+		return err
+	}
+
+	query = `
+        INSERT INTO parcels (shipment_id, length, width, height, weight)
+        VALUES ($1, $2, $3, $4, $5)
+        RETURNING id`
+
+	for i := range shipment.Parcels {
+		parcel := &shipment.Parcels[i]
+
+		err = tx.QueryRowContext(ctx, query, shipment.ID, parcel.Length, parcel.Width, parcel.Height, parcel.Weight).Scan(&parcel.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func insertAddress(ctx context.Context, tx *sql.Tx, address *Address) error {
+	query := `
+        INSERT INTO addresses (name, company, street1, street2, city, state, zip, country, phone, email)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+        RETURNING id`
+
+	args := []any{
+		address.Name,
+		address.Company,
+		address.Street1,
+		address.Street2,
+		address.City,
+		address.State,
+		address.Zip,
+		strings.ToUpper(address.Country),
+		address.Phone,
+		address.Email,
+	}
+
+	return tx.QueryRowContext(ctx, query, args...).Scan(&address.ID)
+}
+
+const shipmentColumns = `
+        shipments.id, shipments.created_at, shipments.updated_at, shipments.status, shipments.reference, shipments.store_id,
+        shipments.shippo_shipment_id, shipments.label_id, shipments.customs, shipments.shippo_customs_declaration_id, shipments.shippo_transaction_id, shipments.version,
+        origin.id, origin.name, origin.company, origin.street1, origin.street2, origin.city, origin.state, origin.zip, origin.country, origin.phone, origin.email,
+        destination.id, destination.name, destination.company, destination.street1, destination.street2, destination.city, destination.state, destination.zip, destination.country, destination.phone, destination.email`
+
+const shipmentJoins = `
+        INNER JOIN addresses origin ON origin.id = shipments.address_from_id
+        INNER JOIN addresses destination ON destination.id = shipments.address_to_id`
+
+// customsColumn stores a shipment's customs declaration in the nullable
+// customs jsonb column, mapping a nil declaration to NULL both ways.
+type customsColumn struct {
+	declaration **customs.Declaration
+}
+
+func (c customsColumn) Value() (driver.Value, error) {
+	if *c.declaration == nil {
+		return nil, nil
+	}
+
+	return json.Marshal(*c.declaration)
+}
+
+func (c customsColumn) Scan(src any) error {
+	if src == nil {
+		*c.declaration = nil
+		return nil
+	}
+
+	b, ok := src.([]byte)
+	if !ok {
+		return fmt.Errorf("customs: unsupported column type %T", src)
+	}
+
+	var declaration customs.Declaration
+
+	err := json.Unmarshal(b, &declaration)
+	if err != nil {
+		return err
+	}
+
+	*c.declaration = &declaration
+	return nil
+}
+
+func shipmentDest(shipment *Shipment) []any {
+	return []any{
+		&shipment.ID,
+		&shipment.CreatedAt,
+		&shipment.UpdatedAt,
+		&shipment.Status,
+		&shipment.Reference,
+		&shipment.StoreID,
+		&shipment.ShippoShipmentID,
+		&shipment.LabelID,
+		customsColumn{&shipment.Customs},
+		&shipment.ShippoCustomsID,
+		&shipment.ShippoTransactionID,
+		&shipment.Version,
+		&shipment.AddressFrom.ID,
+		&shipment.AddressFrom.Name,
+		&shipment.AddressFrom.Company,
+		&shipment.AddressFrom.Street1,
+		&shipment.AddressFrom.Street2,
+		&shipment.AddressFrom.City,
+		&shipment.AddressFrom.State,
+		&shipment.AddressFrom.Zip,
+		&shipment.AddressFrom.Country,
+		&shipment.AddressFrom.Phone,
+		&shipment.AddressFrom.Email,
+		&shipment.AddressTo.ID,
+		&shipment.AddressTo.Name,
+		&shipment.AddressTo.Company,
+		&shipment.AddressTo.Street1,
+		&shipment.AddressTo.Street2,
+		&shipment.AddressTo.City,
+		&shipment.AddressTo.State,
+		&shipment.AddressTo.Zip,
+		&shipment.AddressTo.Country,
+		&shipment.AddressTo.Phone,
+		&shipment.AddressTo.Email,
+	}
+}
+
+func (m ShipmentModel) Get(id int64) (*Shipment, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `SELECT` + shipmentColumns + `
+        FROM shipments` + shipmentJoins + `
+        WHERE shipments.id = $1`
+
+	var shipment Shipment
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(shipmentDest(&shipment)...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	err = m.loadParcels(ctx, &shipment)
+	if err != nil {
+		return nil, err
+	}
+
+	return &shipment, nil
+}
+
+func (m ShipmentModel) GetAll(status string, filters Filters) ([]*Shipment, Metadata, error) {
+	query := fmt.Sprintf(`
+        SELECT count(*) OVER(),`+shipmentColumns+`
+        FROM shipments`+shipmentJoins+`
+        WHERE (shipments.status = $1 OR $1 = '')
+        ORDER BY shipments.%s %s, shipments.id ASC
+        LIMIT $2 OFFSET $3`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, status, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	shipments := []*Shipment{}
+
+	for rows.Next() {
+		var shipment Shipment
+
+		err := rows.Scan(append([]any{&totalRecords}, shipmentDest(&shipment)...)...)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		shipments = append(shipments, &shipment)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	err = m.loadParcels(ctx, shipments...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return shipments, metadata, nil
+}
+
+// GetByTrackingNumber returns the shipment whose label has the given
+// tracking number.
+func (m ShipmentModel) GetByTrackingNumber(trackingNumber string) (*Shipment, error) {
+	if trackingNumber == "" {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `SELECT` + shipmentColumns + `
+        FROM shipments` + shipmentJoins + `
+        INNER JOIN labels ON labels.id = shipments.label_id
+        WHERE labels.tracking_number = $1
+        ORDER BY shipments.id DESC
+        LIMIT 1`
+
+	var shipment Shipment
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, trackingNumber).Scan(shipmentDest(&shipment)...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	err = m.loadParcels(ctx, &shipment)
+	if err != nil {
+		return nil, err
+	}
+
+	return &shipment, nil
+}
+
+// GetDueForTracking returns up to limit shipments with a label that are not
+// yet delivered or returned and have not been tracked within staleAfter,
+// least recently tracked first.
+func (m ShipmentModel) GetDueForTracking(staleAfter time.Duration, limit int) ([]*Shipment, error) {
+	query := `
+        SELECT` + shipmentColumns + `
+        FROM shipments` + shipmentJoins + `
+        WHERE shipments.status = ANY($1) AND shipments.label_id IS NOT NULL
+        AND (shipments.tracked_at IS NULL OR shipments.tracked_at < NOW() - make_interval(secs => $2))
+        ORDER BY shipments.tracked_at ASC NULLS FIRST, shipments.id ASC
+        LIMIT $3`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	statuses := []string{ShipmentStatusLabeled, ShipmentStatusInTransit}
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(statuses), staleAfter.Seconds(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	shipments := []*Shipment{}
+
+	for rows.Next() {
+		var shipment Shipment
+
+		err := rows.Scan(shipmentDest(&shipment)...)
+		if err != nil {
+			return nil, err
+		}
+
+		shipments = append(shipments, &shipment)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	err = m.loadParcels(ctx, shipments...)
+	if err != nil {
+		return nil, err
+	}
+
+	return shipments, nil
+}
+
+// MarkTracked records that the shipment's tracking was just checked without
+// recording any events, so that a shipment the carrier can't track waits
+// for the next interval instead of staying at the front of the queue.
+func (m ShipmentModel) MarkTracked(id int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, `UPDATE shipments SET tracked_at = NOW() WHERE id = $1`, id)
+	return err
+}
+
+func (m ShipmentModel) loadParcels(ctx context.Context, shipments ...*Shipment) error {
+	if len(shipments) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(shipments))
+	byID := make(map[int64]*Shipment, len(shipments))
+
+	for i, shipment := range shipments {
+		ids[i] = shipment.ID
+		byID[shipment.ID] = shipment
+		shipment.Parcels = []Parcel{}
+	}
+
+	query := `
+        SELECT id, shipment_id, length, width, height, weight
+        FROM parcels
+        WHERE shipment_id = ANY($1)
+        ORDER BY id`
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			parcel     Parcel
+			shipmentID int64
+		)
+
+		err := rows.Scan(&parcel.ID, &shipmentID, &parcel.Length, &parcel.Width, &parcel.Height, &parcel.Weight)
+		if err != nil {
+			return err
+		}
+
+		byID[shipmentID].Parcels = append(byID[shipmentID].Parcels, parcel)
+	}
+
+	return rows.Err()
+}
+
+func (m ShipmentModel) Update(shipment *Shipment) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return updateShipment(ctx, m.DB, shipment)
+}
+
+// rowQuerier is satisfied by both *sql.DB and *sql.Tx, so updates can run on
+// their own or as part of a larger transaction.
+type rowQuerier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func updateShipment(ctx context.Context, db rowQuerier, shipment *Shipment) error {
+	query := `
+        UPDATE shipments
+        SET status = $1, reference = $2, shippo_shipment_id = $3, label_id = $4, customs = $5, shippo_customs_declaration_id = $6,
+            updated_at = NOW(), version = version + 1
+        WHERE id = $7 AND version = $8
+        RETURNING updated_at, version`
+
+	args := []any{
+		shipment.Status,
+		shipment.Reference,
+		shipment.ShippoShipmentID,
+		shipment.LabelID,
+		customsColumn{&shipment.Customs},
+		shipment.ShippoCustomsID,
+		shipment.ID,
+		shipment.Version,
+	}
+
+	err := db.QueryRowContext(ctx, query, args...).Scan(&shipment.UpdatedAt, &shipment.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RecordTransaction saves the Shippo transaction of a label bought for a
+// purchasing shipment before the label itself is saved, so that a label
+// that fails to save can still be found and reconciled. Like AttachLabel it
+// is keyed on the purchasing status rather than the version.
+func (m ShipmentModel) RecordTransaction(shipment *Shipment, transactionID string) error {
+	query := `
+        UPDATE shipments
+        SET shippo_transaction_id = $1, updated_at = NOW(), version = version + 1
+        WHERE id = $2 AND status = $3
+        RETURNING updated_at, version`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, transactionID, shipment.ID, ShipmentStatusPurchasing).Scan(&shipment.UpdatedAt, &shipment.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return fmt.Errorf("%w: shipment %d is no longer purchasing", ErrInvalidTransition, shipment.ID)
+		default:
+			return err
+		}
+	}
+
+	shipment.ShippoTransactionID = transactionID
+	return nil
+}
+
+// AttachLabel saves a purchased label and moves the purchasing shipment to
+// labeled in one transaction. The update is keyed on the purchasing status
+// rather than the version: the label is already paid for, so an unrelated
+// edit made during the purchase must not make it fail.
+func (m ShipmentModel) AttachLabel(shipment *Shipment, label *Label) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = insertLabel(ctx, tx, label)
+	if err != nil {
+		return err
+	}
+
+	query := `
+        UPDATE shipments
+        SET status = $1, label_id = $2, shippo_shipment_id = COALESCE(NULLIF($3, ''), shippo_shipment_id), updated_at = NOW(), version = version + 1
+        WHERE id = $4 AND status = $5
+        RETURNING shippo_shipment_id, updated_at, version`
+
+	args := []any{ShipmentStatusLabeled, label.ID, shipment.ShippoShipmentID, shipment.ID, ShipmentStatusPurchasing}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&shipment.ShippoShipmentID, &shipment.UpdatedAt, &shipment.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return fmt.Errorf("%w: shipment %d is no longer purchasing", ErrInvalidTransition, shipment.ID)
+		default:
+			return err
+		}
+	}
+
+	shipment.Status = ShipmentStatusLabeled
+	shipment.LabelID = &label.ID
+
+	return tx.Commit()
+}
@@ -0,0 +1,220 @@
+package data
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/pistolricks/ShippingApi/internal/validator"
+)
+
+const (
+	StorePlatformShopify     = "shopify"
+	StorePlatformWooCommerce = "woocommerce"
+)
+
+var StorePlatforms = []string{StorePlatformShopify, StorePlatformWooCommerce}
+
+// Store is a storefront allowed to request rates with its own API key. The
+// plaintext key is only set when the key is generated. NotifyCustomers
+// controls whether the store's customers are emailed about their shipments.
+type Store struct {
+	ID              int64     `json:"id"`
+	CreatedAt       time.Time `json:"created_at"`
+	Name            string    `json:"name"`
+	Platform        string    `json:"platform"`
+	OriginZIPCode   string    `json:"origin_zip_code"`
+	NotifyCustomers bool      `json:"notify_customers"`
+	APIKey          string    `json:"api_key,omitempty"`
+	Version         int       `json:"version"`
+
+	keyHash []byte
+}
+
+// GenerateKey gives the store a new random API key.
+func (s *Store) GenerateKey() {
+	s.APIKey = rand.Text()
+
+	hash := sha256.Sum256([]byte(s.APIKey))
+	s.keyHash = hash[:]
+}
+
+func ValidateStore(v *validator.Validator, store *Store) {
+	v.Check(store.Name != "", "name", "must be provided")
+	v.Check(len(store.Name) <= 200, "name", "must not be more than 200 bytes long")
+
+	v.Check(validator.PermittedValue(store.Platform, StorePlatforms...), "platform", "invalid platform")
+
+	v.Check(store.OriginZIPCode != "", "origin_zip_code", "must be provided")
+	v.Check(validator.Matches(store.OriginZIPCode, validator.ZIPCodeRX), "origin_zip_code", "must be a 5 digit ZIP code")
+}
+
+type StoreModel struct {
+	DB *sql.DB
+}
+
+// Insert creates the store with a freshly generated API key.
+func (m StoreModel) Insert(store *Store) error {
+	store.GenerateKey()
+
+	query := `
+        INSERT INTO stores (name, platform, origin_zip_code, notify_customers, key_hash)
+        VALUES ($1, $2, $3, $4, $5)
+        RETURNING id, created_at, version`
+
+	args := []any{store.Name, store.Platform, store.OriginZIPCode, store.NotifyCustomers, store.keyHash}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&store.ID, &store.CreatedAt, &store.Version)
+}
+
+func (m StoreModel) Get(id int64) (*Store, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+        SELECT id, created_at, name, platform, origin_zip_code, notify_customers, version
+        FROM stores
+        WHERE id = $1`
+
+	return m.get(query, id)
+}
+
+// GetForKey returns the store owning the plaintext API key.
+func (m StoreModel) GetForKey(key string) (*Store, error) {
+	hash := sha256.Sum256([]byte(key))
+
+	query := `
+        SELECT id, created_at, name, platform, origin_zip_code, notify_customers, version
+        FROM stores
+        WHERE key_hash = $1`
+
+	return m.get(query, hash[:])
+}
+
+func (m StoreModel) get(query string, arg any) (*Store, error) {
+	var store Store
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, arg).Scan(
+		&store.ID,
+		&store.CreatedAt,
+		&store.Name,
+		&store.Platform,
+		&store.OriginZIPCode,
+		&store.NotifyCustomers,
+		&store.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &store, nil
+}
+
+func (m StoreModel) GetAll() ([]*Store, error) {
+	query := `
+        SELECT id, created_at, name, platform, origin_zip_code, notify_customers, version
+        FROM stores
+        ORDER BY id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stores := []*Store{}
+
+	for rows.Next() {
+		var store Store
+
+		err := rows.Scan(
+			&store.ID,
+			&store.CreatedAt,
+			&store.Name,
+			&store.Platform,
+			&store.OriginZIPCode,
+			&store.NotifyCustomers,
+			&store.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		stores = append(stores, &store)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return stores, nil
+}
+
+func (m StoreModel) Update(store *Store) error {
+	query := `
+        UPDATE stores
+        SET name = $1, origin_zip_code = $2, notify_customers = $3, version = version + 1
+        WHERE id = $4 AND version = $5
+        RETURNING version`
+
+	args := []any{store.Name, store.OriginZIPCode, store.NotifyCustomers, store.ID, store.Version}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&store.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RotateKey replaces the store's API key, invalidating the old one.
+func (m StoreModel) RotateKey(store *Store) error {
+	store.GenerateKey()
+
+	query := `
+        UPDATE stores
+        SET key_hash = $1, version = version + 1
+        WHERE id = $2 AND version = $3
+        RETURNING version`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, store.keyHash, store.ID, store.Version).Scan(&store.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
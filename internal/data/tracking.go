@@ -0,0 +1,144 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type TrackingEvent struct {
+	ID            int64     `json:"id"`
+	ShipmentID    int64     `json:"-"`
+	Status        string    `json:"status"`
+	Description   string    `json:"description"`
+	City          string    `json:"city,omitempty"`
+	State         string    `json:"state,omitempty"`
+	Zip           string    `json:"zip,omitempty"`
+	Country       string    `json:"country,omitempty"`
+	OccurredAt    time.Time `json:"occurred_at"`
+	CarrierStatus string    `json:"carrier_status,omitempty"`
+}
+
+type TrackingEventModel struct {
+	DB *sql.DB
+}
+
+// Record adds the events that are not already in the shipment's history and
+// marks the shipment as tracked, returning how many events were new. Carriers
+// return the whole history on every poll, so events already stored are
+// skipped rather than duplicated.
+func (m TrackingEventModel) Record(shipmentID int64, events []*TrackingEvent) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	inserted, err := recordTrackingEvents(ctx, tx, shipmentID, events)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+
+	return inserted, nil
+}
+
+// recordTrackingEvents inserts the new events and marks the shipment as
+// tracked inside tx.
+func recordTrackingEvents(ctx context.Context, tx *sql.Tx, shipmentID int64, events []*TrackingEvent) (int, error) {
+	query := `
+        INSERT INTO tracking_events (shipment_id, status, description, city, state, zip, country, occurred_at, carrier_status)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+        ON CONFLICT (shipment_id, occurred_at, carrier_status, description) DO NOTHING
+        RETURNING id`
+
+	inserted := 0
+
+	for _, event := range events {
+		args := []any{
+			shipmentID,
+			event.Status,
+			event.Description,
+			event.City,
+			event.State,
+			event.Zip,
+			event.Country,
+			event.OccurredAt,
+			event.CarrierStatus,
+		}
+
+		err := tx.QueryRowContext(ctx, query, args...).Scan(&event.ID)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			continue
+		case err != nil:
+			return 0, err
+		}
+
+		event.ShipmentID = shipmentID
+		inserted++
+	}
+
+	_, err := tx.ExecContext(ctx, `UPDATE shipments SET tracked_at = NOW() WHERE id = $1`, shipmentID)
+	if err != nil {
+		return 0, err
+	}
+
+	return inserted, nil
+}
+
+// GetForShipment returns the tracking history of a shipment, newest first.
+func (m TrackingEventModel) GetForShipment(shipmentID int64) ([]*TrackingEvent, error) {
+	query := `
+        SELECT id, shipment_id, status, description, city, state, zip, country, occurred_at, carrier_status
+        FROM tracking_events
+        WHERE shipment_id = $1
+        ORDER BY occurred_at DESC, id DESC`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, shipmentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []*TrackingEvent{}
+
+	for rows.Next() {
+		var event TrackingEvent
+
+		err := rows.Scan(
+			&event.ID,
+			&event.ShipmentID,
+			&event.Status,
+			&event.Description,
+			&event.City,
+			&event.State,
+			&event.Zip,
+			&event.Country,
+			&event.OccurredAt,
+			&event.CarrierStatus,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		events = append(events, &event)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
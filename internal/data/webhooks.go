@@ -0,0 +1,114 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var (
+	ErrDuplicateWebhookEvent = errors.New("duplicate webhook event")
+)
+
+type WebhookEvent struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Provider  string    `json:"provider"`
+	EventID   string    `json:"event_id"`
+	EventType string    `json:"event_type"`
+}
+
+type WebhookEventModel struct {
+	DB *sql.DB
+}
+
+// ApplyTrackingUpdate records a tracking webhook together with its effects:
+// the new tracking events and, when changed is true, the shipment's new
+// status. It all happens in one transaction, so a delivery is either
+// applied in full or not at all, and ErrDuplicateWebhookEvent is returned
+// for a delivery that has already been applied.
+func (m WebhookEventModel) ApplyTrackingUpdate(event *WebhookEvent, shipment *Shipment, events []*TrackingEvent, changed bool) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	err = insertWebhookEvent(ctx, tx, event)
+	if err != nil {
+		return 0, err
+	}
+
+	inserted, err := recordTrackingEvents(ctx, tx, shipment.ID, events)
+	if err != nil {
+		return 0, err
+	}
+
+	if changed {
+		err = updateShipment(ctx, tx, shipment)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+
+	return inserted, nil
+}
+
+// ApplyLabelUpdate records a transaction webhook together with the label's
+// tracking number and URLs, in one transaction.
+func (m WebhookEventModel) ApplyLabelUpdate(event *WebhookEvent, label *Label) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = insertWebhookEvent(ctx, tx, event)
+	if err != nil {
+		return err
+	}
+
+	query := `
+        UPDATE labels
+        SET tracking_number = $1, tracking_url = $2, label_url = $3
+        WHERE id = $4`
+
+	_, err = tx.ExecContext(ctx, query, label.TrackingNumber, label.TrackingURL, label.LabelURL, label.ID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func insertWebhookEvent(ctx context.Context, tx *sql.Tx, event *WebhookEvent) error {
+	query := `
+        INSERT INTO webhook_events (provider, event_id, event_type)
+        VALUES ($1, $2, $3)
+        ON CONFLICT (provider, event_id) DO NOTHING
+        RETURNING id, created_at`
+
+	err := tx.QueryRowContext(ctx, query, event.Provider, event.EventID, event.EventType).Scan(&event.ID, &event.CreatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrDuplicateWebhookEvent
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,132 @@
+package delivery
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// DateLayout is the format of dates in holiday files and delivery windows.
+const DateLayout = "2006-01-02"
+
+// Calendar is the set of holidays on which mail is neither accepted nor
+// delivered.
+type Calendar struct {
+	federal bool
+	dates   map[string]bool
+}
+
+// FederalHolidays returns a calendar of the US federal holidays, computed for
+// any year. A holiday falling on a Sunday is observed on the Monday after, as
+// the Postal Service does.
+func FederalHolidays() *Calendar {
+	return &Calendar{federal: true}
+}
+
+// NewCalendar returns a calendar of exactly the given dates.
+func NewCalendar(dates ...time.Time) *Calendar {
+	c := &Calendar{dates: make(map[string]bool, len(dates))}
+
+	for _, date := range dates {
+		c.dates[date.Format(DateLayout)] = true
+	}
+
+	return c
+}
+
+// LoadCalendar reads a holiday calendar from a JSON file holding an array of
+// YYYY-MM-DD dates.
+func LoadCalendar(path string) (*Calendar, error) {
+	js, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var values []string
+
+	err = json.Unmarshal(js, &values)
+	if err != nil {
+		return nil, fmt.Errorf("delivery: invalid holiday calendar %s: %w", path, err)
+	}
+
+	dates := make([]time.Time, len(values))
+
+	for i, value := range values {
+		dates[i], err = time.Parse(DateLayout, value)
+		if err != nil {
+			return nil, fmt.Errorf("delivery: invalid holiday %q in %s", value, path)
+		}
+	}
+
+	return NewCalendar(dates...), nil
+}
+
+// IsHoliday reports whether the date of t is a holiday.
+func (c *Calendar) IsHoliday(t time.Time) bool {
+	if c == nil {
+		return false
+	}
+
+	if c.dates[t.Format(DateLayout)] {
+		return true
+	}
+
+	return c.federal && isFederalHoliday(t)
+}
+
+func isFederalHoliday(t time.Time) bool {
+	year, month, day := t.Date()
+
+	for _, holiday := range federalHolidays(year) {
+		if holiday.Month() == month && holiday.Day() == day {
+			return true
+		}
+
+		if holiday.Weekday() == time.Sunday {
+			observed := holiday.AddDate(0, 0, 1)
+			if observed.Month() == month && observed.Day() == day {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// federalHolidays lists the federal holidays of year (5 U.S.C. 6103).
+func federalHolidays(year int) []time.Time {
+	date := func(month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	}
+
+	return []time.Time{
+		date(time.January, 1),
+		nthWeekday(year, time.January, time.Monday, 3),    // Birthday of Martin Luther King, Jr.
+		nthWeekday(year, time.February, time.Monday, 3),   // Washington's Birthday
+		lastWeekday(year, time.May, time.Monday),          // Memorial Day
+		date(time.June, 19),                               // Juneteenth
+		date(time.July, 4),                                // Independence Day
+		nthWeekday(year, time.September, time.Monday, 1),  // Labor Day
+		nthWeekday(year, time.October, time.Monday, 2),    // Columbus Day
+		date(time.November, 11),                           // Veterans Day
+		nthWeekday(year, time.November, time.Thursday, 4), // Thanksgiving Day
+		date(time.December, 25),
+	}
+}
+
+// nthWeekday returns the nth weekday of month, counting from 1.
+func nthWeekday(year int, month time.Month, weekday time.Weekday, n int) time.Time {
+	first := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	offset := (int(weekday) - int(first.Weekday()) + 7) % 7
+
+	return first.AddDate(0, 0, offset+7*(n-1))
+}
+
+// lastWeekday returns the last weekday of month.
+func lastWeekday(year int, month time.Month, weekday time.Weekday) time.Time {
+	last := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC)
+	offset := (int(last.Weekday()) - int(weekday) + 7) % 7
+
+	return last.AddDate(0, 0, -offset)
+}
@@ -0,0 +1,113 @@
+// Package delivery estimates when a parcel will arrive. A parcel handed to
+// the warehouse ships on the next acceptance day (a weekday that is not a
+// holiday, moving to the next one after the daily cutoff), then takes the
+// number of delivery days its mail class's service standard promises.
+package delivery
+
+import (
+	"time"
+)
+
+// Standard is the service standard of a mail class: the range of delivery
+// days between acceptance and delivery, and which days count as delivery
+// days. Monday to Friday always do.
+type Standard struct {
+	MinDays  int
+	MaxDays  int
+	Saturday bool
+	Sunday   bool
+	Holidays bool
+}
+
+// Window is the expected delivery date range of a parcel.
+type Window struct {
+	ShipDate string `json:"shipDate"`
+	Earliest string `json:"earliest"`
+	Latest   string `json:"latest"`
+	MinDays  int    `json:"minDays"`
+	MaxDays  int    `json:"maxDays"`
+}
+
+// Estimator turns service standards into delivery dates for one warehouse.
+type Estimator struct {
+	// Cutoff is the time of day, in Location, after which parcels ship on
+	// the next acceptance day.
+	Cutoff   time.Duration
+	Location *time.Location
+	Holidays *Calendar
+}
+
+// DefaultCutoff is the daily cutoff used when none is configured.
+const DefaultCutoff = 15 * time.Hour
+
+// NewEstimator returns an Estimator for a warehouse in loc with a daily
+// cutoff and holiday calendar.
+func NewEstimator(cutoff time.Duration, loc *time.Location, holidays *Calendar) *Estimator {
+	if loc == nil {
+		loc = time.Local
+	}
+
+	return &Estimator{Cutoff: cutoff, Location: loc, Holidays: holidays}
+}
+
+// ShipDate returns the date a parcel ready at t is accepted by the carrier.
+func (e *Estimator) ShipDate(t time.Time) time.Time {
+	t = t.In(e.Location)
+
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, e.Location)
+	if t.Sub(day) >= e.Cutoff {
+		day = day.AddDate(0, 0, 1)
+	}
+
+	for !e.acceptanceDay(day) {
+		day = day.AddDate(0, 0, 1)
+	}
+
+	return day
+}
+
+// Estimate returns the delivery window of a parcel ready at t and sent
+// under standard.
+func (e *Estimator) Estimate(t time.Time, standard Standard) Window {
+	ship := e.ShipDate(t)
+
+	return Window{
+		ShipDate: ship.Format(DateLayout),
+		Earliest: e.addDeliveryDays(ship, standard, standard.MinDays).Format(DateLayout),
+		Latest:   e.addDeliveryDays(ship, standard, standard.MaxDays).Format(DateLayout),
+		MinDays:  standard.MinDays,
+		MaxDays:  standard.MaxDays,
+	}
+}
+
+func (e *Estimator) acceptanceDay(day time.Time) bool {
+	weekday := day.Weekday()
+
+	return weekday != time.Saturday && weekday != time.Sunday && !e.Holidays.IsHoliday(day)
+}
+
+func (e *Estimator) addDeliveryDays(day time.Time, standard Standard, n int) time.Time {
+	for n > 0 {
+		day = day.AddDate(0, 0, 1)
+		if e.deliveryDay(day, standard) {
+			n--
+		}
+	}
+
+	return day
+}
+
+func (e *Estimator) deliveryDay(day time.Time, standard Standard) bool {
+	switch day.Weekday() {
+	case time.Saturday:
+		if !standard.Saturday {
+			return false
+		}
+	case time.Sunday:
+		if !standard.Sunday {
+			return false
+		}
+	}
+
+	return standard.Holidays || !e.Holidays.IsHoliday(day)
+}
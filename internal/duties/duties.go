@@ -0,0 +1,255 @@
+// Package duties estimates the import duties and taxes owed on a
+// cross-border order. Rates come from a per-country table: goods valued at or
+// below a country's de minimis threshold enter free of duty (or tax), duty is
+// charged per customs item at the rate of its HS code, and tax (VAT, GST) is
+// charged on the goods plus whatever the country adds to its tax base.
+//
+// The figures are estimates for showing shoppers a landed cost, not a
+// substitute for the assessment customs makes on arrival. All amounts are in
+// US dollars.
+package duties
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/pistolricks/ShippingApi/internal/customs"
+	"github.com/pistolricks/ShippingApi/internal/validator"
+)
+
+// ErrNoRates is returned by Estimate for a country missing from the table.
+var ErrNoRates = errors.New("duties: no duty and tax rates configured")
+
+// ErrCurrency is returned by Estimate for items not declared in US dollars.
+var ErrCurrency = errors.New("duties: items must be declared in USD")
+
+var tariffPrefixRX = regexp.MustCompile(`^\d{2,10}$`)
+
+// Country holds the import rules of one destination. Rates are fractions, so
+// 0.2 is 20%.
+type Country struct {
+	// DutyDeMinimis and TaxDeMinimis are the order values at or below which
+	// no duty or tax is charged. Zero means every order is dutiable or
+	// taxable.
+	DutyDeMinimis float64 `json:"dutyDeMinimis"`
+	TaxDeMinimis  float64 `json:"taxDeMinimis"`
+
+	// DutyRate applies to items whose tariff number matches no entry of
+	// Tariffs. Tariffs is keyed by HS code prefix (2 to 10 digits) and the
+	// longest matching prefix wins.
+	DutyRate float64            `json:"dutyRate"`
+	Tariffs  map[string]float64 `json:"tariffs,omitempty"`
+
+	// FreeTradeOrigins lists origin countries whose goods enter duty free
+	// under a trade agreement.
+	FreeTradeOrigins []string `json:"freeTradeOrigins,omitempty"`
+
+	// DutyOnShipping values goods for duty including shipping (CIF) rather
+	// than without (FOB).
+	DutyOnShipping bool `json:"dutyOnShipping"`
+
+	TaxRate float64 `json:"taxRate"`
+	// TaxOnShipping and TaxOnDuty add shipping and duty to the tax base, as
+	// most VAT and GST systems do.
+	TaxOnShipping bool `json:"taxOnShipping"`
+	TaxOnDuty     bool `json:"taxOnDuty"`
+}
+
+// Table maps ISO 3166-1 alpha-2 country codes to their import rules.
+type Table map[string]Country
+
+// DefaultTable is used when no table is configured. Its thresholds are
+// converted to US dollars and rounded, so it should be replaced by a
+// maintained table in production.
+var DefaultTable = Table{
+	"CA": {DutyDeMinimis: 110, TaxDeMinimis: 30, DutyRate: 0.08, FreeTradeOrigins: []string{"US", "MX"}, TaxRate: 0.05, TaxOnShipping: true, TaxOnDuty: true},
+	"MX": {DutyDeMinimis: 50, TaxDeMinimis: 50, DutyRate: 0.19, FreeTradeOrigins: []string{"US", "CA"}, DutyOnShipping: true, TaxRate: 0.16, TaxOnShipping: true, TaxOnDuty: true},
+	"GB": {DutyDeMinimis: 170, DutyRate: 0.04, Tariffs: map[string]float64{"61": 0.12, "62": 0.12, "64": 0.08, "85": 0.02}, DutyOnShipping: true, TaxRate: 0.2, TaxOnShipping: true, TaxOnDuty: true},
+	"DE": {DutyDeMinimis: 160, DutyRate: 0.04, Tariffs: map[string]float64{"61": 0.12, "62": 0.12, "64": 0.08, "85": 0.02}, DutyOnShipping: true, TaxRate: 0.19, TaxOnShipping: true, TaxOnDuty: true},
+	"FR": {DutyDeMinimis: 160, DutyRate: 0.04, Tariffs: map[string]float64{"61": 0.12, "62": 0.12, "64": 0.08, "85": 0.02}, DutyOnShipping: true, TaxRate: 0.2, TaxOnShipping: true, TaxOnDuty: true},
+	"AU": {DutyDeMinimis: 650, DutyRate: 0.05, TaxRate: 0.1, TaxOnShipping: true, TaxOnDuty: true},
+	"JP": {DutyDeMinimis: 65, TaxDeMinimis: 65, DutyRate: 0.05, DutyOnShipping: true, TaxRate: 0.1, TaxOnShipping: true, TaxOnDuty: true},
+}
+
+// LoadTable reads a duty and tax table from a JSON object file keyed by
+// country code.
+func LoadTable(path string) (Table, error) {
+	js, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var table Table
+
+	err = json.Unmarshal(js, &table)
+	if err != nil {
+		return nil, fmt.Errorf("duties: invalid rate table %s: %w", path, err)
+	}
+
+	if len(table) == 0 {
+		return nil, fmt.Errorf("duties: rate table %s is empty", path)
+	}
+
+	out := make(Table, len(table))
+
+	for code, country := range table {
+		code = strings.ToUpper(code)
+
+		if !validator.IsCountryCode(code) {
+			return nil, fmt.Errorf("duties: invalid country code %q in %s", code, path)
+		}
+
+		if !validRate(country.DutyRate) || !validRate(country.TaxRate) || country.DutyDeMinimis < 0 || country.TaxDeMinimis < 0 {
+			return nil, fmt.Errorf("duties: invalid rates for %s in %s", code, path)
+		}
+
+		for prefix, rate := range country.Tariffs {
+			if !validRate(rate) || !validator.Matches(prefix, tariffPrefixRX) {
+				return nil, fmt.Errorf("duties: invalid tariff %q for %s in %s", prefix, code, path)
+			}
+		}
+
+		for i, origin := range country.FreeTradeOrigins {
+			country.FreeTradeOrigins[i] = strings.ToUpper(origin)
+		}
+
+		out[code] = country
+	}
+
+	return out, nil
+}
+
+func validRate(rate float64) bool {
+	return rate >= 0 && rate <= 1
+}
+
+// Line is the duty charged on one customs item.
+type Line struct {
+	Description  string  `json:"description"`
+	TariffNumber string  `json:"tariffNumber,omitempty"`
+	Value        float64 `json:"value"`
+	DutyRate     float64 `json:"dutyRate"`
+	Duty         float64 `json:"duty"`
+}
+
+// Breakdown splits a landed cost by when the shopper pays it.
+type Breakdown struct {
+	DueAtCheckout float64 `json:"dueAtCheckout"`
+	DueOnDelivery float64 `json:"dueOnDelivery"`
+}
+
+// Estimate is the landed cost of an order shipped to one country. DDP and DDU
+// show the same duties and taxes paid by the shipper at checkout or by the
+// recipient on delivery.
+type Estimate struct {
+	CountryCode        string    `json:"countryCode"`
+	Currency           string    `json:"currency"`
+	GoodsValue         float64   `json:"goodsValue"`
+	Shipping           float64   `json:"shipping"`
+	Duty               float64   `json:"duty"`
+	Tax                float64   `json:"tax"`
+	LandedCost         float64   `json:"landedCost"`
+	BelowDutyDeMinimis bool      `json:"belowDutyDeMinimis"`
+	BelowTaxDeMinimis  bool      `json:"belowTaxDeMinimis"`
+	Lines              []Line    `json:"lines"`
+	DDP                Breakdown `json:"ddp"`
+	DDU                Breakdown `json:"ddu"`
+}
+
+// Estimate computes the duties and taxes on items shipped to countryCode for
+// the given shipping price.
+func (t Table) Estimate(countryCode string, items []customs.Item, currency string, shipping float64) (*Estimate, error) {
+	countryCode = strings.ToUpper(countryCode)
+
+	country, ok := t[countryCode]
+	if !ok {
+		return nil, fmt.Errorf("%w for %s", ErrNoRates, countryCode)
+	}
+
+	if currency != "USD" {
+		return nil, ErrCurrency
+	}
+
+	est := &Estimate{
+		CountryCode: countryCode,
+		Currency:    currency,
+		Shipping:    shipping,
+		Lines:       make([]Line, 0, len(items)),
+	}
+
+	for _, item := range items {
+		est.GoodsValue += item.Value
+	}
+	est.GoodsValue = round(est.GoodsValue)
+
+	est.BelowDutyDeMinimis = est.GoodsValue <= country.DutyDeMinimis
+	est.BelowTaxDeMinimis = est.GoodsValue <= country.TaxDeMinimis
+
+	for _, item := range items {
+		line := Line{
+			Description:  item.Description,
+			TariffNumber: item.TariffNumber,
+			Value:        item.Value,
+		}
+
+		if !est.BelowDutyDeMinimis && !slices.Contains(country.FreeTradeOrigins, item.OriginCountry) {
+			line.DutyRate = country.dutyRate(item.TariffNumber)
+
+			base := item.Value
+			if country.DutyOnShipping && est.GoodsValue > 0 {
+				// Shipping is apportioned to items by value.
+				base += shipping * item.Value / est.GoodsValue
+			}
+
+			line.Duty = round(base * line.DutyRate)
+		}
+
+		est.Duty += line.Duty
+		est.Lines = append(est.Lines, line)
+	}
+	est.Duty = round(est.Duty)
+
+	if !est.BelowTaxDeMinimis {
+		base := est.GoodsValue
+		if country.TaxOnShipping {
+			base += shipping
+		}
+		if country.TaxOnDuty {
+			base += est.Duty
+		}
+
+		est.Tax = round(base * country.TaxRate)
+	}
+
+	fees := round(est.Duty + est.Tax)
+
+	est.LandedCost = round(est.GoodsValue + shipping + fees)
+	est.DDP = Breakdown{DueAtCheckout: est.LandedCost}
+	est.DDU = Breakdown{DueAtCheckout: round(est.GoodsValue + shipping), DueOnDelivery: fees}
+
+	return est, nil
+}
+
+// dutyRate returns the rate of the longest Tariffs prefix of tariffNumber,
+// or the country's default rate.
+func (c Country) dutyRate(tariffNumber string) float64 {
+	rate, matched := c.DutyRate, 0
+
+	for prefix, r := range c.Tariffs {
+		if len(prefix) > matched && strings.HasPrefix(tariffNumber, prefix) {
+			rate, matched = r, len(prefix)
+		}
+	}
+
+	return rate
+}
+
+func round(f float64) float64 {
+	return math.Round(f*100) / 100
+}
@@ -3,9 +3,6 @@ package mailer
 import (
 	"bytes"
 	"embed"
-	"time"
-
-	"github.com/wneessen/go-mail"
 
 	ht "html/template"
 	tt "text/template"
@@ -14,76 +11,81 @@ import (
 //go:embed "templates"
 var templateFS embed.FS
 
-type Mailer struct {
-	client *mail.Client
-	sender string
+// Sender delivers rendered messages. SMTPSender sends them over SMTP,
+// DirSender writes them to .eml files and MemorySender keeps them in memory,
+// so the application can run without a mail server.
+type Sender interface {
+	Send(message *Message) error
 }
 
-func New(host string, port int, username, password, sender string) (*Mailer, error) {
-	client, err := mail.NewClient(
-		host,
-		mail.WithSMTPAuth(mail.SMTPAuthLogin),
-		mail.WithPort(port),
-		mail.WithUsername(username),
-		mail.WithPassword(password),
-		mail.WithTimeout(5*time.Second),
-	)
-	if err != nil {
-		return nil, err
-	}
+// Mailer renders templated emails and hands them to a Sender.
+type Mailer struct {
+	sender Sender
+}
 
-	mailer := &Mailer{
-		client: client,
-		sender: sender,
-	}
+func New(sender Sender) *Mailer {
+	return &Mailer{sender: sender}
+}
 
-	return mailer, nil
+// Message is a rendered email, ready to be delivered.
+type Message struct {
+	Recipient string
+	Subject   string
+	PlainBody string
+	HTMLBody  string
 }
 
-func (m *Mailer) Send(recipient string, templateFile string, data any) error {
+// Render executes the subject, plainBody and htmlBody templates of
+// templateFile with data.
+func Render(recipient string, templateFile string, data any) (*Message, error) {
 	textTmpl, err := tt.New("").ParseFS(templateFS, "templates/"+templateFile)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	subject := new(bytes.Buffer)
 	err = textTmpl.ExecuteTemplate(subject, "subject", data)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	plainBody := new(bytes.Buffer)
 	err = textTmpl.ExecuteTemplate(plainBody, "plainBody", data)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	htmlTmpl, err := ht.New("").ParseFS(templateFS, "templates/"+templateFile)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	htmlBody := new(bytes.Buffer)
 	err = htmlTmpl.ExecuteTemplate(htmlBody, "htmlBody", data)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	msg := mail.NewMsg()
-
-	err = msg.To(recipient)
-	if err != nil {
-		return err
+	message := &Message{
+		Recipient: recipient,
+		Subject:   subject.String(),
+		PlainBody: plainBody.String(),
+		HTMLBody:  htmlBody.String(),
 	}
 
-	err = msg.From(m.sender)
+	return message, nil
+}
+
+func (m *Mailer) Send(recipient string, templateFile string, data any) error {
+	message, err := Render(recipient, templateFile, data)
 	if err != nil {
 		return err
 	}
 
-	msg.Subject(subject.String())
-	msg.SetBodyString(mail.TypeTextPlain, plainBody.String())
-	msg.AddAlternativeString(mail.TypeTextHTML, htmlBody.String())
+	return m.Deliver(message)
+}
 
-	return m.client.DialAndSend(msg)
+// Deliver sends a message that has already been rendered.
+func (m *Mailer) Deliver(message *Message) error {
+	return m.sender.Send(message)
 }
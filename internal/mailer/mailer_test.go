@@ -0,0 +1,167 @@
+package mailer_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pistolricks/ShippingApi/internal/mailer"
+)
+
+func TestRender(t *testing.T) {
+	shipment := map[string]any{
+		"name":           "Alice",
+		"reference":      "ORDER-1001",
+		"storeName":      "Example Store",
+		"carrier":        "USPS",
+		"trackingNumber": "9400100000000000000001",
+		"trackingURL":    "https://tools.usps.com/go/TrackConfirmAction?tLabels=9400100000000000000001",
+	}
+
+	tests := []struct {
+		templateFile string
+		data         map[string]any
+		want         string
+	}{
+		{templateFile: "user_welcome.tmpl", data: map[string]any{"activationToken": "WELCOMETOKEN", "userID": 7}, want: "WELCOMETOKEN"},
+		{templateFile: "token_activation.tmpl", data: map[string]any{"activationToken": "ACTIVATIONTOKEN"}, want: "ACTIVATIONTOKEN"},
+		{templateFile: "token_password_reset.tmpl", data: map[string]any{"passwordResetToken": "RESETTOKEN"}, want: "RESETTOKEN"},
+		{templateFile: "shipment_label_created.tmpl", data: shipment, want: "9400100000000000000001"},
+		{templateFile: "shipment_out_for_delivery.tmpl", data: shipment, want: "9400100000000000000001"},
+		{templateFile: "shipment_delivered.tmpl", data: shipment, want: "9400100000000000000001"},
+		{templateFile: "shipment_exception.tmpl", data: shipment, want: "9400100000000000000001"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.templateFile, func(t *testing.T) {
+			message, err := mailer.Render("alice@example.com", tt.templateFile, tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if message.Recipient != "alice@example.com" {
+				t.Errorf("got recipient %q", message.Recipient)
+			}
+			if strings.TrimSpace(message.Subject) == "" {
+				t.Error("got an empty subject")
+			}
+			if !strings.Contains(message.PlainBody, tt.want) {
+				t.Errorf("plain body does not contain %q:\n%s", tt.want, message.PlainBody)
+			}
+			if !strings.Contains(message.HTMLBody, tt.want) {
+				t.Errorf("HTML body does not contain %q:\n%s", tt.want, message.HTMLBody)
+			}
+		})
+	}
+}
+
+func TestRenderEscapesHTML(t *testing.T) {
+	message, err := mailer.Render("alice@example.com", "token_activation.tmpl", map[string]any{"activationToken": "<script>"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(message.HTMLBody, "<script>") {
+		t.Errorf("HTML body is not escaped:\n%s", message.HTMLBody)
+	}
+	if !strings.Contains(message.PlainBody, "<script>") {
+		t.Errorf("plain body is escaped:\n%s", message.PlainBody)
+	}
+}
+
+func TestRenderUnknownTemplate(t *testing.T) {
+	_, err := mailer.Render("alice@example.com", "missing.tmpl", nil)
+	if err == nil {
+		t.Error("got no error for a missing template")
+	}
+}
+
+func TestMailerSend(t *testing.T) {
+	sender := mailer.NewMemorySender(nil)
+	m := mailer.New(sender)
+
+	err := m.Send("alice@example.com", "token_password_reset.tmpl", map[string]any{"passwordResetToken": "RESETTOKEN"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	messages := sender.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("got %d messages; want 1", len(messages))
+	}
+
+	if messages[0].Recipient != "alice@example.com" || !strings.Contains(messages[0].PlainBody, "RESETTOKEN") {
+		t.Errorf("got message %+v", messages[0])
+	}
+
+	sender.Reset()
+
+	if n := len(sender.Messages()); n != 0 {
+		t.Errorf("got %d messages after Reset; want 0", n)
+	}
+}
+
+func TestMemorySenderLimit(t *testing.T) {
+	sender := mailer.NewMemorySender(nil)
+
+	for i := range mailer.MemoryLimit + 5 {
+		err := sender.Send(&mailer.Message{Recipient: "alice@example.com", Subject: fmt.Sprint(i)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	messages := sender.Messages()
+	if len(messages) != mailer.MemoryLimit {
+		t.Fatalf("got %d messages; want %d", len(messages), mailer.MemoryLimit)
+	}
+
+	if first, last := messages[0].Subject, messages[len(messages)-1].Subject; first != "5" || last != fmt.Sprint(mailer.MemoryLimit+4) {
+		t.Errorf("got messages %s to %s; want the newest %d", first, last, mailer.MemoryLimit)
+	}
+}
+
+func TestDirSender(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+
+	sender, err := mailer.NewDirSender(dir, "Shipping <no-reply@example.com>")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for range 2 {
+		err = mailer.New(sender).Send("alice@example.com", "token_activation.tmpl", map[string]any{"activationToken": "ACTIVATIONTOKEN"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("got %d .eml files; want 2", len(files))
+	}
+
+	b, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{"To: <alice@example.com>", "ACTIVATIONTOKEN"} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("%s does not contain %q", filepath.Base(files[0]), want)
+		}
+	}
+}
+
+func TestNewSMTPSenderMissingHost(t *testing.T) {
+	_, err := mailer.NewSMTPSender("", 25, "", "", "no-reply@example.com")
+	if !errors.Is(err, mailer.ErrMissingSMTPHost) {
+		t.Errorf("got error %v; want %v", err, mailer.ErrMissingSMTPHost)
+	}
+}
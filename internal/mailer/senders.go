@@ -0,0 +1,172 @@
+package mailer
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/wneessen/go-mail"
+)
+
+// ErrMissingSMTPHost is returned by NewSMTPSender when no SMTP host is set.
+var ErrMissingSMTPHost = errors.New("mailer: SMTP host is not configured")
+
+// newMsg builds the MIME message for a rendered email.
+func newMsg(from string, message *Message) (*mail.Msg, error) {
+	msg := mail.NewMsg()
+
+	err := msg.To(message.Recipient)
+	if err != nil {
+		return nil, err
+	}
+
+	err = msg.From(from)
+	if err != nil {
+		return nil, err
+	}
+
+	msg.Subject(message.Subject)
+	msg.SetBodyString(mail.TypeTextPlain, message.PlainBody)
+	msg.AddAlternativeString(mail.TypeTextHTML, message.HTMLBody)
+
+	return msg, nil
+}
+
+// SMTPSender sends messages through an SMTP server.
+type SMTPSender struct {
+	client *mail.Client
+	from   string
+}
+
+func NewSMTPSender(host string, port int, username, password, from string) (*SMTPSender, error) {
+	if host == "" {
+		return nil, ErrMissingSMTPHost
+	}
+
+	client, err := mail.NewClient(
+		host,
+		mail.WithSMTPAuth(mail.SMTPAuthLogin),
+		mail.WithPort(port),
+		mail.WithUsername(username),
+		mail.WithPassword(password),
+		mail.WithTimeout(5*time.Second),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SMTPSender{client: client, from: from}, nil
+}
+
+func (s *SMTPSender) Send(message *Message) error {
+	msg, err := newMsg(s.from, message)
+	if err != nil {
+		return err
+	}
+
+	return s.client.DialAndSend(msg)
+}
+
+// DirSender writes each message to its own .eml file in a directory, where
+// it can be opened with any mail client.
+type DirSender struct {
+	dir  string
+	from string
+	seq  atomic.Int64
+}
+
+// NewDirSender creates dir if needed and returns a DirSender writing to it.
+func NewDirSender(dir, from string) (*DirSender, error) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DirSender{dir: dir, from: from}, nil
+}
+
+func (s *DirSender) Send(message *Message) error {
+	msg, err := newMsg(s.from, message)
+	if err != nil {
+		return err
+	}
+
+	// Files sort in the order they were sent; the sequence number keeps
+	// names unique within the same nanosecond.
+	name := fmt.Sprintf("%s-%04d-%s.eml",
+		time.Now().UTC().Format("20060102T150405.000000000"),
+		s.seq.Add(1)%10000,
+		fileSafe(message.Recipient),
+	)
+
+	return msg.WriteToFile(filepath.Join(s.dir, name))
+}
+
+// fileSafe replaces the characters of an email address that don't belong in
+// a file name.
+func fileSafe(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '@':
+			return r
+		default:
+			return '_'
+		}
+	}, s)
+}
+
+// MemoryLimit is the most messages a MemorySender keeps. Older messages are
+// dropped, so a long-running server using -mail-transport=memory doesn't
+// grow without bound.
+const MemoryLimit = 1000
+
+// MemorySender keeps the last MemoryLimit sent messages in memory, for tests
+// to assert on. With a logger, it also logs each message as it is sent.
+type MemorySender struct {
+	logger   *slog.Logger
+	mu       sync.Mutex
+	messages []Message
+}
+
+// NewMemorySender returns a MemorySender. logger may be nil.
+func NewMemorySender(logger *slog.Logger) *MemorySender {
+	return &MemorySender{logger: logger}
+}
+
+func (s *MemorySender) Send(message *Message) error {
+	s.mu.Lock()
+	if len(s.messages) >= MemoryLimit {
+		s.messages = slices.Delete(s.messages, 0, len(s.messages)-MemoryLimit+1)
+	}
+	s.messages = append(s.messages, *message)
+	s.mu.Unlock()
+
+	if s.logger != nil {
+		s.logger.Info("email sent", "recipient", message.Recipient, "subject", message.Subject)
+	}
+
+	return nil
+}
+
+// Messages returns a copy of the messages kept so far, oldest first.
+func (s *MemorySender) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Message(nil), s.messages...)
+}
+
+// Reset forgets the messages sent so far.
+func (s *MemorySender) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.messages = nil
+}
@@ -0,0 +1,115 @@
+// Package notify emails customers as their shipments progress: when the
+// label is created, when the package is out for delivery, when it is
+// delivered, and when something goes wrong on the way.
+package notify
+
+import (
+	"errors"
+
+	"github.com/pistolricks/ShippingApi/internal/data"
+	"github.com/pistolricks/ShippingApi/internal/tracking"
+)
+
+// Mailer sends, or queues for sending, a templated email.
+type Mailer interface {
+	Send(recipient, templateFile string, data any) error
+}
+
+// Service sends shipment notifications, at most one of each kind per
+// shipment.
+type Service struct {
+	mailer Mailer
+	models data.Models
+}
+
+func New(mailer Mailer, models data.Models) *Service {
+	return &Service{mailer: mailer, models: models}
+}
+
+// templates maps notification kinds to their email templates.
+var templates = map[string]string{
+	data.NotificationLabelCreated:   "shipment_label_created.tmpl",
+	data.NotificationOutForDelivery: "shipment_out_for_delivery.tmpl",
+	data.NotificationDelivered:      "shipment_delivered.tmpl",
+	data.NotificationException:      "shipment_exception.tmpl",
+}
+
+// KindForStatus returns the notification to send for a normalized tracking
+// status, or "" when the status doesn't warrant one.
+func KindForStatus(status string) string {
+	switch status {
+	case tracking.StatusOutForDelivery:
+		return data.NotificationOutForDelivery
+	case tracking.StatusDelivered:
+		return data.NotificationDelivered
+	case tracking.StatusFailure, tracking.StatusReturnToSender:
+		return data.NotificationException
+	}
+
+	return ""
+}
+
+// Notify emails the shipment's recipient about it. Nothing is sent when the
+// recipient has no email address, when the shipment's store has opted out,
+// or when the notification was already sent. A notification that fails to
+// send is released so the next event can retry it.
+func (s *Service) Notify(shipment *data.Shipment, kind string) error {
+	templateFile, ok := templates[kind]
+	if !ok || shipment.AddressTo.Email == "" {
+		return nil
+	}
+
+	input := map[string]any{
+		"name":      shipment.AddressTo.Name,
+		"reference": shipment.Reference,
+	}
+
+	if shipment.StoreID != nil {
+		store, err := s.models.Stores.Get(*shipment.StoreID)
+		if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+			return err
+		}
+
+		if store != nil {
+			if !store.NotifyCustomers {
+				return nil
+			}
+
+			input["storeName"] = store.Name
+		}
+	}
+
+	if shipment.LabelID != nil {
+		label, err := s.models.Labels.Get(*shipment.LabelID)
+		if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+			return err
+		}
+
+		if label != nil {
+			input["carrier"] = label.Carrier
+			input["trackingNumber"] = label.TrackingNumber
+			input["trackingURL"] = label.TrackingURL
+		}
+	}
+
+	notification := &data.Notification{
+		ShipmentID: shipment.ID,
+		Kind:       kind,
+		Recipient:  shipment.AddressTo.Email,
+	}
+
+	err := s.models.Notifications.Claim(notification)
+	if err != nil {
+		if errors.Is(err, data.ErrDuplicateNotification) {
+			return nil
+		}
+		return err
+	}
+
+	err = s.mailer.Send(notification.Recipient, templateFile, input)
+	if err != nil {
+		return errors.Join(err, s.models.Notifications.Release(notification))
+	}
+
+	return nil
+}
@@ -0,0 +1,305 @@
+// Package packing chooses shipping boxes for order items. It runs an
+// extreme-point 3D bin-packing heuristic: items are placed largest first at
+// the lowest free corner of a box in whichever orientation fits, and a new
+// box is opened from the inventory when the open ones are full.
+package packing
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+)
+
+// ErrItemTooLarge is returned when an item fits in no box of the inventory,
+// either by size or by weight.
+var ErrItemTooLarge = errors.New("item does not fit in any box")
+
+// Box is a carton in the inventory. Dimensions are inner dimensions in
+// inches; TareWeight and MaxWeight are in ounces, MaxWeight including the
+// tare. A MaxWeight of zero means no limit beyond the box's dimensions.
+type Box struct {
+	Name       string  `json:"name"`
+	Length     float64 `json:"length"`
+	Width      float64 `json:"width"`
+	Height     float64 `json:"height"`
+	TareWeight float64 `json:"tareWeight"`
+	MaxWeight  float64 `json:"maxWeight"`
+}
+
+func (b Box) volume() float64 {
+	return b.Length * b.Width * b.Height
+}
+
+// DefaultBoxes is the inventory used when none is configured.
+var DefaultBoxes = []Box{
+	{Name: "small", Length: 8, Width: 6, Height: 4, TareWeight: 3, MaxWeight: 20 * 16},
+	{Name: "medium", Length: 12, Width: 9, Height: 6, TareWeight: 6, MaxWeight: 40 * 16},
+	{Name: "large", Length: 18, Width: 14, Height: 10, TareWeight: 12, MaxWeight: 70 * 16},
+	{Name: "extra-large", Length: 24, Width: 18, Height: 18, TareWeight: 20, MaxWeight: 70 * 16},
+}
+
+// LoadBoxes reads a box inventory from a JSON array file.
+func LoadBoxes(path string) ([]Box, error) {
+	js, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var boxes []Box
+
+	err = json.Unmarshal(js, &boxes)
+	if err != nil {
+		return nil, fmt.Errorf("packing: invalid box inventory %s: %w", path, err)
+	}
+
+	for _, box := range boxes {
+		if box.Name == "" || box.Length <= 0 || box.Width <= 0 || box.Height <= 0 || box.TareWeight < 0 || box.MaxWeight < 0 {
+			return nil, fmt.Errorf("packing: invalid box %q in %s", box.Name, path)
+		}
+	}
+
+	if len(boxes) == 0 {
+		return nil, fmt.Errorf("packing: box inventory %s is empty", path)
+	}
+
+	return boxes, nil
+}
+
+// Item is one unit to pack. Dimensions are in inches and Weight in ounces.
+type Item struct {
+	SKU    string  `json:"sku"`
+	Length float64 `json:"length"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+	Weight float64 `json:"weight"`
+}
+
+func (it Item) volume() float64 {
+	return it.Length * it.Width * it.Height
+}
+
+// orientations returns the distinct axis-aligned rotations of the item.
+func (it Item) orientations() [][3]float64 {
+	l, w, h := it.Length, it.Width, it.Height
+
+	all := [][3]float64{{l, w, h}, {l, h, w}, {w, l, h}, {w, h, l}, {h, l, w}, {h, w, l}}
+
+	var out [][3]float64
+	for _, o := range all {
+		if !slices.Contains(out, o) {
+			out = append(out, o)
+		}
+	}
+
+	return out
+}
+
+// Parcel is a packed box. Weight includes the box's tare weight and
+// FillRatio is the share of the box's inner volume taken by items.
+type Parcel struct {
+	Box       Box      `json:"box"`
+	SKUs      []string `json:"skus"`
+	Weight    float64  `json:"weight"`
+	FillRatio float64  `json:"fillRatio"`
+
+	placed []placement
+	points [][3]float64
+}
+
+type placement struct {
+	pos, dims [3]float64
+}
+
+// Pack packs items into boxes from the inventory. It returns ErrItemTooLarge
+// if any single item fits in no box.
+func Pack(boxes []Box, items []Item) ([]Parcel, error) {
+	if len(boxes) == 0 {
+		return nil, errors.New("packing: empty box inventory")
+	}
+
+	boxes = slices.Clone(boxes)
+	slices.SortFunc(boxes, func(a, b Box) int {
+		return cmpFloat(a.volume(), b.volume())
+	})
+
+	remaining := slices.Clone(items)
+	slices.SortStableFunc(remaining, func(a, b Item) int {
+		return cmpFloat(b.volume(), a.volume())
+	})
+
+	for _, item := range remaining {
+		if !slices.ContainsFunc(boxes, func(box Box) bool { return fitsEmpty(box, item) }) {
+			return nil, fmt.Errorf("%w: %s", ErrItemTooLarge, item.SKU)
+		}
+	}
+
+	var parcels []Parcel
+
+	for len(remaining) > 0 {
+		parcel, left := bestBox(boxes, remaining)
+		parcels = append(parcels, parcel)
+		remaining = left
+	}
+
+	return parcels, nil
+}
+
+// bestBox returns the smallest box that takes every remaining item or,
+// when none does, the box taking the most item volume.
+func bestBox(boxes []Box, items []Item) (Parcel, []Item) {
+	var (
+		best       Parcel
+		bestLeft   []Item
+		bestVolume = -1.0
+	)
+
+	for _, box := range boxes {
+		parcel, left := fill(box, items)
+		if len(parcel.placed) == 0 {
+			continue
+		}
+
+		if len(left) == 0 {
+			return parcel, nil
+		}
+
+		packed := 0.0
+		for _, p := range parcel.placed {
+			packed += p.dims[0] * p.dims[1] * p.dims[2]
+		}
+
+		if packed > bestVolume {
+			best, bestLeft, bestVolume = parcel, left, packed
+		}
+	}
+
+	return best, bestLeft
+}
+
+// fill packs as many items as possible into one box and returns the parcel
+// along with the items left over.
+func fill(box Box, items []Item) (Parcel, []Item) {
+	parcel := Parcel{
+		Box:    box,
+		Weight: box.TareWeight,
+		points: [][3]float64{{0, 0, 0}},
+	}
+
+	var left []Item
+
+	for _, item := range items {
+		if !parcel.add(item) {
+			left = append(left, item)
+		}
+	}
+
+	used := 0.0
+	for _, p := range parcel.placed {
+		used += p.dims[0] * p.dims[1] * p.dims[2]
+	}
+	if v := box.volume(); v > 0 {
+		parcel.FillRatio = used / v
+	}
+
+	return parcel, left
+}
+
+// add places item at the lowest, then nearest, extreme point where one of
+// its orientations fits, reporting whether it was placed.
+func (p *Parcel) add(item Item) bool {
+	if p.Box.MaxWeight > 0 && p.Weight+item.Weight > p.Box.MaxWeight {
+		return false
+	}
+
+	slices.SortFunc(p.points, func(a, b [3]float64) int {
+		if c := cmpFloat(a[2], b[2]); c != 0 {
+			return c
+		}
+		if c := cmpFloat(a[1], b[1]); c != 0 {
+			return c
+		}
+		return cmpFloat(a[0], b[0])
+	})
+
+	for i, point := range p.points {
+		for _, dims := range item.orientations() {
+			if !p.fits(point, dims) {
+				continue
+			}
+
+			p.placed = append(p.placed, placement{pos: point, dims: dims})
+			p.SKUs = append(p.SKUs, item.SKU)
+			p.Weight += item.Weight
+
+			p.points = append(p.points[:i], p.points[i+1:]...)
+			p.points = append(p.points,
+				[3]float64{point[0] + dims[0], point[1], point[2]},
+				[3]float64{point[0], point[1] + dims[1], point[2]},
+				[3]float64{point[0], point[1], point[2] + dims[2]},
+			)
+
+			return true
+		}
+	}
+
+	return false
+}
+
+// fits reports whether a box of dims placed at pos stays inside the parcel
+// and clear of every placed item.
+func (p *Parcel) fits(pos, dims [3]float64) bool {
+	bounds := [3]float64{p.Box.Length, p.Box.Width, p.Box.Height}
+
+	for axis := range 3 {
+		if pos[axis]+dims[axis] > bounds[axis]+epsilon {
+			return false
+		}
+	}
+
+	for _, other := range p.placed {
+		if overlaps(pos, dims, other.pos, other.dims) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func fitsEmpty(box Box, item Item) bool {
+	if box.MaxWeight > 0 && box.TareWeight+item.Weight > box.MaxWeight {
+		return false
+	}
+
+	for _, dims := range item.orientations() {
+		if dims[0] <= box.Length+epsilon && dims[1] <= box.Width+epsilon && dims[2] <= box.Height+epsilon {
+			return true
+		}
+	}
+
+	return false
+}
+
+func overlaps(aPos, aDims, bPos, bDims [3]float64) bool {
+	for axis := range 3 {
+		if aPos[axis]+aDims[axis] <= bPos[axis]+epsilon || bPos[axis]+bDims[axis] <= aPos[axis]+epsilon {
+			return false
+		}
+	}
+	return true
+}
+
+// epsilon absorbs floating point error when comparing dimensions.
+const epsilon = 1e-9
+
+func cmpFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
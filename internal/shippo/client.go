@@ -0,0 +1,38 @@
+package shippo
+
+import (
+	"errors"
+
+	goshippo "github.com/coldbrewcloud/go-shippo"
+	"github.com/coldbrewcloud/go-shippo/client"
+	shippoerrors "github.com/coldbrewcloud/go-shippo/errors"
+)
+
+// ErrMissingToken is returned by New when no Shippo private token is set.
+var ErrMissingToken = errors.New("shippo: private API token is not configured")
+
+// Client wraps the go-shippo client with the subset of the Shippo API used
+// for rating shipments and purchasing labels.
+type Client struct {
+	client *client.Client
+}
+
+// New creates a Client authenticated with a Shippo private token.
+func New(privateToken string) (*Client, error) {
+	if privateToken == "" {
+		return nil, ErrMissingToken
+	}
+
+	return &Client{client: goshippo.NewClient(privateToken)}, nil
+}
+
+// IsRejected reports whether err is Shippo refusing the request itself (a 4xx
+// response), as opposed to a transport or server failure.
+func IsRejected(err error) bool {
+	var apiErr *shippoerrors.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Status >= 400 && apiErr.Status < 500
+	}
+
+	return errors.Is(err, ErrLabelPurchaseFailed)
+}
@@ -0,0 +1,68 @@
+package shippo
+
+import "github.com/coldbrewcloud/go-shippo/models"
+
+// CustomsItem is one line of a customs declaration. NetWeight is in ounces
+// and Value is the total for the whole quantity.
+type CustomsItem struct {
+	Description   string
+	TariffNumber  string
+	OriginCountry string
+	Quantity      int
+	NetWeight     float64
+	Value         float64
+	Currency      string
+}
+
+// CustomsDeclaration is the customs information Shippo needs to buy a label
+// for an international shipment.
+type CustomsDeclaration struct {
+	ContentsType        string
+	ContentsExplanation string
+	NonDeliveryOption   string
+	CertifySigner       string
+	Incoterm            string
+	EELPFC              string
+	AESITN              string
+	Items               []CustomsItem
+}
+
+func (d CustomsDeclaration) input() *models.CustomsDeclarationInput {
+	items := make([]*models.CustomsItemInput, len(d.Items))
+
+	for i, item := range d.Items {
+		items[i] = &models.CustomsItemInput{
+			Description:   item.Description,
+			Quantity:      item.Quantity,
+			NetWeight:     formatFloat(item.NetWeight),
+			MassUnit:      models.MassUnitOunce,
+			ValueAmount:   formatFloat(item.Value),
+			ValueCurrency: item.Currency,
+			OriginCountry: item.OriginCountry,
+			TariffNumber:  item.TariffNumber,
+		}
+	}
+
+	return &models.CustomsDeclarationInput{
+		CertifySigner:       d.CertifySigner,
+		Certify:             true,
+		Items:               items,
+		NonDeliveryOption:   d.NonDeliveryOption,
+		ContentsType:        d.ContentsType,
+		ContentsExplanation: d.ContentsExplanation,
+		EEL_PFC:             d.EELPFC,
+		AES_ITN:             d.AESITN,
+		Incoterm:            d.Incoterm,
+	}
+}
+
+// CreateCustomsDeclaration registers a customs declaration with Shippo and
+// returns its object ID.
+func (c *Client) CreateCustomsDeclaration(declaration CustomsDeclaration) (string, error) {
+	created, err := c.client.CreateCustomsDeclaration(declaration.input())
+	if err != nil {
+		return "", err
+	}
+
+	return created.ObjectID, nil
+}
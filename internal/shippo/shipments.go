@@ -1 +1,197 @@
 package shippo
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/coldbrewcloud/go-shippo/models"
+)
+
+// ErrRateNotInShipment is returned by ShipmentRate when the rate was quoted
+// for a different shipment.
+var ErrRateNotInShipment = errors.New("shippo: rate does not belong to the shipment")
+
+// Address is a postal address accepted by the shipments endpoints.
+type Address struct {
+	Name    string `json:"name"`
+	Company string `json:"company,omitempty"`
+	Street1 string `json:"street1"`
+	Street2 string `json:"street2,omitempty"`
+	City    string `json:"city"`
+	State   string `json:"state"`
+	Zip     string `json:"zip"`
+	Country string `json:"country"`
+	Phone   string `json:"phone,omitempty"`
+	Email   string `json:"email,omitempty"`
+}
+
+// Parcel describes a single package. Dimensions are in inches and weight is
+// in ounces, matching the units used by the USPS rates endpoint.
+type Parcel struct {
+	Length float64 `json:"length"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+	Weight float64 `json:"weight"`
+}
+
+// Rate is a purchasable rate returned by Shippo for a shipment.
+type Rate struct {
+	ID            string `json:"id"`
+	Provider      string `json:"provider"`
+	ServiceLevel  string `json:"service_level"`
+	ServiceToken  string `json:"service_token"`
+	Amount        string `json:"amount"`
+	Currency      string `json:"currency"`
+	EstimatedDays int    `json:"estimated_days,omitempty"`
+	DurationTerms string `json:"duration_terms,omitempty"`
+}
+
+// Shipment is a Shippo shipment together with the rates it was quoted.
+type Shipment struct {
+	ID       string   `json:"id"`
+	Status   string   `json:"status"`
+	Rates    []Rate   `json:"rates"`
+	Messages []string `json:"messages,omitempty"`
+}
+
+func (a Address) input() *models.AddressInput {
+	return &models.AddressInput{
+		Name:    a.Name,
+		Company: a.Company,
+		Street1: a.Street1,
+		Street2: a.Street2,
+		City:    a.City,
+		State:   a.State,
+		Zip:     a.Zip,
+		Country: strings.ToUpper(a.Country),
+		Phone:   a.Phone,
+		Email:   a.Email,
+	}
+}
+
+func (p Parcel) input() *models.ParcelInput {
+	return &models.ParcelInput{
+		Length:       formatFloat(p.Length),
+		Width:        formatFloat(p.Width),
+		Height:       formatFloat(p.Height),
+		DistanceUnit: models.DistanceUnitInch,
+		Weight:       formatFloat(p.Weight),
+		MassUnit:     models.MassUnitOunce,
+	}
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', 2, 64)
+}
+
+// CreateShipment creates a Shippo shipment synchronously and returns the
+// rates Shippo quoted for it.
+func (c *Client) CreateShipment(from, to Address, parcels ...Parcel) (*Shipment, error) {
+	return c.createShipment(from, to, nil, parcels)
+}
+
+// CreateShipmentWithCustoms is CreateShipment for an international shipment,
+// attaching the customs declaration declarationID so that the rates include
+// any customs charges.
+func (c *Client) CreateShipmentWithCustoms(from, to Address, declarationID string, parcels ...Parcel) (*Shipment, error) {
+	return c.createShipment(from, to, declarationID, parcels)
+}
+
+// createShipment rates a shipment, attaching the customs declaration when it
+// is not nil. declaration is a Shippo object ID or a declaration input.
+func (c *Client) createShipment(from, to Address, declaration any, parcels []Parcel) (*Shipment, error) {
+	if len(parcels) == 0 {
+		return nil, errors.New("shippo: at least one parcel is required")
+	}
+
+	inputs := make([]*models.ParcelInput, len(parcels))
+	for i, parcel := range parcels {
+		inputs[i] = parcel.input()
+	}
+
+	shipment, err := c.client.CreateShipment(&models.ShipmentInput{
+		AddressFrom:        from.input(),
+		AddressTo:          to.input(),
+		Parcels:            inputs,
+		CustomsDeclaration: declaration,
+		Async:              false,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	out := &Shipment{
+		ID:       shipment.ObjectID,
+		Status:   shipment.Status,
+		Rates:    make([]Rate, 0, len(shipment.Rates)),
+		Messages: messages(shipment.Messages),
+	}
+
+	for _, rate := range shipment.Rates {
+		out.Rates = append(out.Rates, newRate(rate))
+	}
+
+	return out, nil
+}
+
+// ShipmentRate returns the rate rateID after checking that Shippo quoted it
+// for the shipment shipmentID.
+func (c *Client) ShipmentRate(shipmentID, rateID string) (*Rate, error) {
+	if shipmentID == "" {
+		return nil, ErrRateNotInShipment
+	}
+
+	shipment, err := c.client.RetrieveShipment(shipmentID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, rate := range shipment.Rates {
+		if rate != nil && rate.ObjectID == rateID {
+			r := newRate(rate)
+			return &r, nil
+		}
+	}
+
+	return nil, ErrRateNotInShipment
+}
+
+func newRate(rate *models.Rate) Rate {
+	r := Rate{
+		ID:            rate.ObjectID,
+		Provider:      rate.Provider,
+		Amount:        rate.Amount,
+		Currency:      rate.Currency,
+		EstimatedDays: rate.Days,
+		DurationTerms: rate.DurationTerms,
+	}
+
+	if rate.ServiceLevel != nil {
+		r.ServiceLevel = rate.ServiceLevel.Name
+		r.ServiceToken = rate.ServiceLevel.Token
+	}
+
+	return r
+}
+
+func messages(msgs []*models.OutputMessage) []string {
+	var out []string
+
+	for _, msg := range msgs {
+		if msg == nil {
+			continue
+		}
+
+		text := msg.Text
+		if text == "" {
+			text = msg.Message
+		}
+
+		if text != "" {
+			out = append(out, text)
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,81 @@
+package shippo
+
+import (
+	"strings"
+
+	"github.com/coldbrewcloud/go-shippo/models"
+	"github.com/pistolricks/ShippingApi/internal/tracking"
+)
+
+// CarrierToken converts a rate provider name, such as "DHL Express", to the
+// carrier token Shippo's tracking endpoint expects ("dhl_express").
+func CarrierToken(provider string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(provider)), " ", "_")
+}
+
+// Track fetches the tracking history of a package and normalizes it. carrier
+// is a rate provider name or a Shippo carrier token.
+func (c *Client) Track(carrier, trackingNumber string) (*tracking.Result, error) {
+	status, err := c.client.GetTrackingUpdate(CarrierToken(carrier), trackingNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	return trackingResult(status), nil
+}
+
+// trackingResult normalizes a Shippo tracking status, as returned by the
+// tracking endpoint and sent in track_updated webhooks.
+func trackingResult(status *models.TrackingStatus) *tracking.Result {
+	result := &tracking.Result{Events: make([]tracking.Event, 0, len(status.TrackingHistory))}
+
+	for _, dict := range status.TrackingHistory {
+		if dict != nil {
+			result.Events = append(result.Events, trackingEvent(dict))
+		}
+	}
+
+	// The history can lag the current status, so make sure the latest
+	// status is part of it.
+	if current := status.TrackingStatus; current != nil && !current.StatusDate.IsZero() {
+		found := false
+		for _, event := range result.Events {
+			if event.OccurredAt.Equal(current.StatusDate) && event.CarrierStatus == current.Status {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			result.Events = append(result.Events, trackingEvent(current))
+		}
+	}
+
+	tracking.SortNewestFirst(result.Events)
+	result.Status = tracking.Latest(result.Events)
+
+	if !status.ETA.IsZero() {
+		eta := status.ETA
+		result.EstimatedDelivery = &eta
+	}
+
+	return result
+}
+
+func trackingEvent(dict *models.TrackingStatusDict) tracking.Event {
+	event := tracking.Event{
+		Status:        tracking.FromShippo(dict.Status, dict.StatusDetails),
+		Description:   dict.StatusDetails,
+		OccurredAt:    dict.StatusDate.UTC(),
+		CarrierStatus: dict.Status,
+	}
+
+	if dict.Location != nil {
+		event.City = dict.Location.City
+		event.State = dict.Location.State
+		event.Zip = dict.Location.Zip
+		event.Country = strings.ToUpper(dict.Location.Country)
+	}
+
+	return event
+}
@@ -0,0 +1,104 @@
+package shippo
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/coldbrewcloud/go-shippo/models"
+)
+
+// ErrLabelPurchaseFailed is returned when Shippo accepts the transaction
+// request but could not produce a label.
+var ErrLabelPurchaseFailed = errors.New("shippo: label purchase failed")
+
+// Transaction is a purchased label.
+type Transaction struct {
+	ID             string
+	RateID         string
+	Provider       string
+	ServiceLevel   string
+	TrackingNumber string
+	TrackingURL    string
+	LabelURL       string
+	Cost           float64
+	Currency       string
+}
+
+// PurchaseLabel buys a PDF label for a rate previously returned by
+// CreateShipment.
+func (c *Client) PurchaseLabel(rateID string) (*Transaction, error) {
+	rate, err := c.client.RetrieveRate(rateID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check the amount before paying, since newTransaction needs it.
+	_, err = strconv.ParseFloat(rate.Amount, 64)
+	if err != nil {
+		return nil, fmt.Errorf("shippo: invalid rate amount %q: %w", rate.Amount, err)
+	}
+
+	transaction, err := c.client.PurchaseShippingLabel(&models.TransactionInput{
+		Rate:          rateID,
+		LabelFileType: models.LabelFileTypePDF,
+		Async:         false,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return newTransaction(transaction, rate)
+}
+
+// Transaction retrieves a label bought earlier, so that one whose purchase
+// was not recorded can still be saved.
+func (c *Client) Transaction(id string) (*Transaction, error) {
+	transaction, err := c.client.RetrieveTransaction(id)
+	if err != nil {
+		return nil, err
+	}
+
+	rate, err := c.client.RetrieveRate(transaction.Rate)
+	if err != nil {
+		return nil, err
+	}
+
+	return newTransaction(transaction, rate)
+}
+
+// newTransaction converts a Shippo transaction for rate into a Transaction,
+// failing when Shippo could not produce the label.
+func newTransaction(transaction *models.Transaction, rate *models.Rate) (*Transaction, error) {
+	if transaction.Status != models.StatusSuccess {
+		msgs := messages(transaction.Messages)
+		if len(msgs) == 0 {
+			return nil, ErrLabelPurchaseFailed
+		}
+
+		return nil, fmt.Errorf("%w: %s", ErrLabelPurchaseFailed, strings.Join(msgs, "; "))
+	}
+
+	cost, err := strconv.ParseFloat(rate.Amount, 64)
+	if err != nil {
+		return nil, fmt.Errorf("shippo: invalid rate amount %q: %w", rate.Amount, err)
+	}
+
+	out := &Transaction{
+		ID:             transaction.ObjectID,
+		RateID:         rate.ObjectID,
+		Provider:       rate.Provider,
+		TrackingNumber: transaction.TrackingNumber,
+		TrackingURL:    transaction.TrackingURLProvider,
+		LabelURL:       transaction.LabelURL,
+		Cost:           cost,
+		Currency:       rate.Currency,
+	}
+
+	if rate.ServiceLevel != nil {
+		out.ServiceLevel = rate.ServiceLevel.Name
+	}
+
+	return out, nil
+}
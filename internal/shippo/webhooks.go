@@ -0,0 +1,152 @@
+package shippo
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coldbrewcloud/go-shippo/models"
+	"github.com/pistolricks/ShippingApi/internal/tracking"
+)
+
+// SignatureHeader carries the HMAC signature of a webhook delivery, in the
+// form "t=<unix timestamp>,v1=<hex HMAC-SHA256 of timestamp.body>".
+const SignatureHeader = "Shippo-Auth-Signature"
+
+// SignatureTolerance is how far the signature timestamp may be from the
+// current time, which bounds how long a captured delivery can be replayed.
+const SignatureTolerance = 5 * time.Minute
+
+// Webhook event types.
+const (
+	EventTrackUpdated       = "track_updated"
+	EventTransactionCreated = "transaction_created"
+)
+
+// ErrInvalidWebhook is returned by ParseWebhook when the body is not a
+// Shippo webhook payload.
+var ErrInvalidWebhook = errors.New("shippo: invalid webhook payload")
+
+// VerifySignature reports whether header is a valid signature of body for
+// the webhook secret, made within SignatureTolerance of now. An empty secret
+// never verifies.
+func VerifySignature(secret string, body []byte, header string, now time.Time) bool {
+	if secret == "" || header == "" {
+		return false
+	}
+
+	var timestamp, signature string
+
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signature = value
+		}
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+
+	age := now.Sub(time.Unix(unix, 0))
+	if age > SignatureTolerance || age < -SignatureTolerance {
+		return false
+	}
+
+	given, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+
+	return hmac.Equal(given, mac.Sum(nil))
+}
+
+// Webhook is a webhook delivery. Shippo does not number its deliveries, so
+// ID is the SHA-256 of the body: a retried delivery carries the same body
+// and so the same ID.
+type Webhook struct {
+	ID    string          `json:"-"`
+	Event string          `json:"event"`
+	Test  bool            `json:"test"`
+	Data  json.RawMessage `json:"data"`
+}
+
+// ParseWebhook decodes a webhook delivery.
+func ParseWebhook(body []byte) (*Webhook, error) {
+	var webhook Webhook
+
+	err := json.Unmarshal(body, &webhook)
+	if err != nil || webhook.Event == "" {
+		return nil, ErrInvalidWebhook
+	}
+
+	sum := sha256.Sum256(body)
+	webhook.ID = hex.EncodeToString(sum[:])
+
+	return &webhook, nil
+}
+
+// TrackUpdate is the payload of a track_updated event.
+type TrackUpdate struct {
+	Carrier        string
+	TrackingNumber string
+	Result         *tracking.Result
+}
+
+// TrackUpdate decodes the payload of a track_updated event.
+func (w *Webhook) TrackUpdate() (*TrackUpdate, error) {
+	var status models.TrackingStatus
+
+	err := json.Unmarshal(w.Data, &status)
+	if err != nil || status.TrackingNumber == "" {
+		return nil, ErrInvalidWebhook
+	}
+
+	return &TrackUpdate{
+		Carrier:        status.Carrier,
+		TrackingNumber: status.TrackingNumber,
+		Result:         trackingResult(&status),
+	}, nil
+}
+
+// TransactionUpdate is the payload of a transaction_created event.
+type TransactionUpdate struct {
+	ID             string
+	Succeeded      bool
+	TrackingNumber string
+	TrackingURL    string
+	LabelURL       string
+	Messages       []string
+}
+
+// TransactionUpdate decodes the payload of a transaction_created event.
+func (w *Webhook) TransactionUpdate() (*TransactionUpdate, error) {
+	var transaction models.Transaction
+
+	err := json.Unmarshal(w.Data, &transaction)
+	if err != nil || transaction.ObjectID == "" {
+		return nil, ErrInvalidWebhook
+	}
+
+	return &TransactionUpdate{
+		ID:             transaction.ObjectID,
+		Succeeded:      transaction.Status == models.StatusSuccess,
+		TrackingNumber: transaction.TrackingNumber,
+		TrackingURL:    transaction.TrackingURLProvider,
+		LabelURL:       transaction.LabelURL,
+		Messages:       messages(transaction.Messages),
+	}, nil
+}
@@ -0,0 +1,125 @@
+// Package shopify implements the Shopify Carrier Service callback format
+// used to quote shipping rates at checkout.
+package shopify
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+)
+
+// HMACHeader is the header carrying the base64 encoded HMAC-SHA256 of the
+// request body, signed with the app's shared secret.
+const HMACHeader = "X-Shopify-Hmac-Sha256"
+
+// DeliveryDateLayout is the timestamp format Shopify expects for delivery
+// dates.
+const DeliveryDateLayout = "2006-01-02 15:04:05 -0700"
+
+// ouncesPerGram converts item weights, which Shopify sends in grams.
+const ouncesPerGram = 0.03527396195
+
+// Address is an origin or destination in a rate request.
+type Address struct {
+	Country     string `json:"country"`
+	PostalCode  string `json:"postal_code"`
+	Province    string `json:"province"`
+	City        string `json:"city"`
+	Name        string `json:"name"`
+	Address1    string `json:"address1"`
+	Address2    string `json:"address2"`
+	Address3    string `json:"address3"`
+	Phone       string `json:"phone"`
+	Fax         string `json:"fax"`
+	Email       string `json:"email"`
+	AddressType string `json:"address_type"`
+	CompanyName string `json:"company_name"`
+}
+
+// ZIPCode returns the 5 digit ZIP code of a US postal code such as
+// "90210-1234".
+func (a Address) ZIPCode() string {
+	zip, _, _ := strings.Cut(strings.TrimSpace(a.PostalCode), "-")
+	return zip
+}
+
+// Item is a cart line item. Price is in the shop currency's minor unit.
+type Item struct {
+	Name               string `json:"name"`
+	SKU                string `json:"sku"`
+	Quantity           int    `json:"quantity"`
+	Grams              int    `json:"grams"`
+	Price              int64  `json:"price"`
+	Vendor             string `json:"vendor"`
+	RequiresShipping   bool   `json:"requires_shipping"`
+	Taxable            bool   `json:"taxable"`
+	FulfillmentService string `json:"fulfillment_service"`
+	ProductID          int64  `json:"product_id"`
+	VariantID          int64  `json:"variant_id"`
+}
+
+// RateRequest is the body Shopify posts to a carrier service callback URL.
+type RateRequest struct {
+	Rate struct {
+		Origin      Address `json:"origin"`
+		Destination Address `json:"destination"`
+		Items       []Item  `json:"items"`
+		Currency    string  `json:"currency"`
+		Locale      string  `json:"locale"`
+	} `json:"rate"`
+}
+
+// ShippedItems returns the items that require shipping.
+func (r RateRequest) ShippedItems() []Item {
+	var items []Item
+
+	for _, item := range r.Rate.Items {
+		if item.RequiresShipping && item.Quantity > 0 {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+// Ounces returns the total weight of items in ounces.
+func Ounces(items []Item) float64 {
+	grams := 0
+
+	for _, item := range items {
+		grams += item.Grams * item.Quantity
+	}
+
+	return float64(grams) * ouncesPerGram
+}
+
+// Rate is a shipping option returned to Shopify. TotalPrice is in cents and
+// the delivery dates use DeliveryDateLayout.
+type Rate struct {
+	ServiceName     string `json:"service_name"`
+	ServiceCode     string `json:"service_code"`
+	TotalPrice      string `json:"total_price"`
+	Description     string `json:"description,omitempty"`
+	Currency        string `json:"currency"`
+	MinDeliveryDate string `json:"min_delivery_date,omitempty"`
+	MaxDeliveryDate string `json:"max_delivery_date,omitempty"`
+}
+
+// VerifyHMAC reports whether signature is the valid HMAC of body for the
+// given shared secret. An empty secret never verifies.
+func VerifyHMAC(secret string, body []byte, signature string) bool {
+	if secret == "" || signature == "" {
+		return false
+	}
+
+	given, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+
+	return hmac.Equal(given, mac.Sum(nil))
+}
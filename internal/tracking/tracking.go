@@ -0,0 +1,166 @@
+// Package tracking normalizes carrier tracking events into one status
+// vocabulary, so shipments tracked through the USPS Tracking API and through
+// Shippo read the same way.
+package tracking
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+// Normalized tracking statuses.
+const (
+	StatusPreTransit         = "pre_transit"
+	StatusInTransit          = "in_transit"
+	StatusOutForDelivery     = "out_for_delivery"
+	StatusAvailableForPickup = "available_for_pickup"
+	StatusDelivered          = "delivered"
+	StatusReturnToSender     = "return_to_sender"
+	StatusFailure            = "failure"
+	StatusUnknown            = "unknown"
+)
+
+var Statuses = []string{
+	StatusPreTransit,
+	StatusInTransit,
+	StatusOutForDelivery,
+	StatusAvailableForPickup,
+	StatusDelivered,
+	StatusReturnToSender,
+	StatusFailure,
+	StatusUnknown,
+}
+
+// Event is one normalized scan or status change. CarrierStatus keeps the
+// carrier's own code or status so nothing is lost in normalization.
+type Event struct {
+	Status        string
+	Description   string
+	City          string
+	State         string
+	Zip           string
+	Country       string
+	OccurredAt    time.Time
+	CarrierStatus string
+}
+
+// Result is the tracking history of one package, newest event first.
+type Result struct {
+	Status            string
+	EstimatedDelivery *time.Time
+	Events            []Event
+}
+
+// Latest returns the status of the newest event, or StatusUnknown when there
+// are no events.
+func Latest(events []Event) string {
+	if len(events) == 0 {
+		return StatusUnknown
+	}
+
+	newest := events[0]
+	for _, event := range events[1:] {
+		if event.OccurredAt.After(newest.OccurredAt) {
+			newest = event
+		}
+	}
+
+	return newest.Status
+}
+
+// SortNewestFirst orders events by time, newest first.
+func SortNewestFirst(events []Event) {
+	slices.SortStableFunc(events, func(a, b Event) int {
+		return b.OccurredAt.Compare(a.OccurredAt)
+	})
+}
+
+// uspsEventCodes maps USPS tracking event codes to normalized statuses.
+// Codes missing here are classified from the event text.
+var uspsEventCodes = map[string]string{
+	"GX": StatusPreTransit, // Shipping label created
+	"MA": StatusPreTransit, // Pre-shipment info sent to USPS
+	"03": StatusInTransit,  // Accepted at USPS origin facility
+	"OA": StatusInTransit,  // Accepted at USPS origin facility
+	"10": StatusInTransit,  // Processed at USPS facility
+	"L1": StatusInTransit,  // Departed USPS facility
+	"T1": StatusInTransit,  // In transit to next facility
+	"07": StatusInTransit,  // Arrived at post office
+	"OF": StatusOutForDelivery,
+	"DX": StatusOutForDelivery,
+	"01": StatusDelivered,
+	"I0": StatusDelivered, // Delivered, in/at mailbox
+	"17": StatusDelivered, // Picked up at post office
+	"21": StatusFailure,   // No such number
+	"22": StatusFailure,   // Insufficient address
+	"23": StatusFailure,   // Moved, left no address
+	"02": StatusFailure,   // Notice left, no authorized recipient
+	"52": StatusAvailableForPickup,
+	"53": StatusAvailableForPickup,
+	"RS": StatusReturnToSender,
+	"09": StatusReturnToSender,
+	"31": StatusReturnToSender,
+}
+
+// FromUSPS normalizes a USPS tracking event from its event code and event
+// type text.
+func FromUSPS(eventCode, eventType string) string {
+	if status, ok := uspsEventCodes[strings.ToUpper(strings.TrimSpace(eventCode))]; ok {
+		return status
+	}
+
+	return fromText(eventType)
+}
+
+// FromShippo normalizes a Shippo tracking status (PRE_TRANSIT, TRANSIT,
+// DELIVERED, RETURNED, FAILURE or UNKNOWN), using the status details to
+// tell out-for-delivery and pickup events apart from other transit scans.
+func FromShippo(status, details string) string {
+	switch strings.ToUpper(status) {
+	case "PRE_TRANSIT":
+		return StatusPreTransit
+	case "TRANSIT":
+		switch text := fromText(details); text {
+		case StatusOutForDelivery, StatusAvailableForPickup:
+			return text
+		}
+		return StatusInTransit
+	case "DELIVERED":
+		return StatusDelivered
+	case "RETURNED":
+		return StatusReturnToSender
+	case "FAILURE":
+		return StatusFailure
+	}
+
+	return fromText(details)
+}
+
+// fromText classifies an event by its description. The more specific
+// phrases are checked first, since "Out for Delivery" and "Delivered" would
+// otherwise match each other's wording.
+func fromText(text string) string {
+	text = strings.ToLower(text)
+
+	switch {
+	case text == "":
+		return StatusUnknown
+	case strings.Contains(text, "return to sender"), strings.Contains(text, "returned to sender"):
+		return StatusReturnToSender
+	case strings.Contains(text, "out for delivery"):
+		return StatusOutForDelivery
+	case strings.Contains(text, "available for pickup"), strings.Contains(text, "held at post office"):
+		return StatusAvailableForPickup
+	case strings.Contains(text, "undeliverable"), strings.Contains(text, "delivery attempted"),
+		strings.Contains(text, "notice left"), strings.Contains(text, "alert"):
+		return StatusFailure
+	case strings.Contains(text, "delivered"):
+		return StatusDelivered
+	case strings.Contains(text, "label created"), strings.Contains(text, "pre-shipment"),
+		strings.Contains(text, "awaiting item"):
+		return StatusPreTransit
+	default:
+		return StatusInTransit
+	}
+}
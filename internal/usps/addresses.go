@@ -2,36 +2,126 @@ package usps
 
 import (
 	"context"
-	"fmt"
-	"log"
+	"strings"
 
 	"github.com/my-eq/go-usps"
 	"github.com/my-eq/go-usps/models"
 )
 
-func StandardizedAddress(client *usps.Client, address *models.AddressRequest) (*models.AddressResponse, error) {
-
-	req := &models.AddressRequest{
-		Firm:             address.Firm,
-		StreetAddress:    address.StreetAddress,
-		SecondaryAddress: address.SecondaryAddress,
-		City:             address.City,
-		State:            address.State,
-		Urbanization:     address.Urbanization,
-		ZIPCode:          address.ZIPCode,
-		ZIPPlus4:         address.ZIPPlus4,
+// StandardizedAddress asks USPS to standardize address. Failures are
+// reported as one of the package's sentinel errors so callers can tell a bad
+// address apart from an outage.
+func StandardizedAddress(ctx context.Context, client *usps.Client, address *models.AddressRequest) (*models.AddressResponse, error) {
+	resp, err := client.GetAddress(ctx, address)
+	if err != nil {
+		return nil, classifyAddressError(err)
 	}
 
-	resp, err := client.GetAddress(context.Background(), req)
-	if err != nil {
-		log.Fatalf("Error: %v", err)
+	if resp.Address == nil {
+		return nil, ErrAddressNotFound
 	}
 
-	fmt.Printf("Standardized: %s, %s, %s %s\n",
-		resp.Address.StreetAddress,
-		resp.Address.City,
-		resp.Address.State,
-		resp.Address.ZIPCode)
+	return resp, nil
+}
+
+// AddressChange is a field that USPS changed while standardizing an address.
+// Standardized is empty when USPS dropped the field, for example a secondary
+// address that doesn't exist at the street address.
+type AddressChange struct {
+	Field        string `json:"field"`
+	Input        string `json:"input"`
+	Standardized string `json:"standardized"`
+}
+
+// AddressResult is the caller-facing form of an AddressResponse. Y/N flags
+// from USPS are converted to booleans and the raw DPV codes are kept.
+type AddressResult struct {
+	Firm                 string                     `json:"firm,omitempty"`
+	StreetAddress        string                     `json:"streetAddress"`
+	SecondaryAddress     string                     `json:"secondaryAddress,omitempty"`
+	City                 string                     `json:"city"`
+	State                string                     `json:"state"`
+	Urbanization         string                     `json:"urbanization,omitempty"`
+	ZIPCode              string                     `json:"ZIPCode"`
+	ZIPPlus4             string                     `json:"ZIPPlus4,omitempty"`
+	DeliveryPoint        string                     `json:"deliveryPoint,omitempty"`
+	CarrierRoute         string                     `json:"carrierRoute,omitempty"`
+	DPVConfirmation      string                     `json:"DPVConfirmation,omitempty"`
+	DPVCMRA              bool                       `json:"DPVCMRA"`
+	Business             bool                       `json:"business"`
+	Residential          bool                       `json:"residential"`
+	CentralDeliveryPoint bool                       `json:"centralDeliveryPoint"`
+	Vacant               bool                       `json:"vacant"`
+	Corrections          []models.AddressCorrection `json:"corrections,omitempty"`
+	Matches              []models.AddressMatch      `json:"matches,omitempty"`
+	Warnings             []string                   `json:"warnings,omitempty"`
+	Changes              []AddressChange            `json:"changes,omitempty"`
+}
+
+// NewAddressResult builds an AddressResult from a USPS response and records
+// every field whose standardized value differs from what was submitted.
+func NewAddressResult(req *models.AddressRequest, resp *models.AddressResponse) *AddressResult {
+	result := &AddressResult{
+		Firm:        resp.Firm,
+		Corrections: resp.Corrections,
+		Matches:     resp.Matches,
+		Warnings:    resp.Warnings,
+	}
+
+	if addr := resp.Address; addr != nil {
+		result.StreetAddress = addr.StreetAddress
+		result.SecondaryAddress = addr.SecondaryAddress
+		result.City = addr.City
+		result.State = addr.State
+		result.Urbanization = addr.Urbanization
+		result.ZIPCode = addr.ZIPCode
+
+		if addr.ZIPPlus4 != nil {
+			result.ZIPPlus4 = *addr.ZIPPlus4
+		}
+	}
+
+	if info := resp.AdditionalInfo; info != nil {
+		result.DeliveryPoint = info.DeliveryPoint
+		result.CarrierRoute = info.CarrierRoute
+		result.DPVConfirmation = info.DPVConfirmation
+		result.DPVCMRA = info.DPVCMRA == "Y"
+		result.Business = info.Business == "Y"
+		result.Residential = info.Business == "N"
+		result.CentralDeliveryPoint = info.CentralDeliveryPoint == "Y"
+		result.Vacant = info.Vacant == "Y"
+	}
+
+	fields := []struct {
+		name         string
+		input        string
+		standardized string
+	}{
+		{"firm", req.Firm, result.Firm},
+		{"streetAddress", req.StreetAddress, result.StreetAddress},
+		{"secondaryAddress", req.SecondaryAddress, result.SecondaryAddress},
+		{"city", req.City, result.City},
+		{"state", req.State, result.State},
+		{"urbanization", req.Urbanization, result.Urbanization},
+		{"ZIPCode", req.ZIPCode, result.ZIPCode},
+		{"ZIPPlus4", req.ZIPPlus4, result.ZIPPlus4},
+	}
+
+	for _, field := range fields {
+		if normalize(field.input) == normalize(field.standardized) {
+			continue
+		}
+
+		result.Changes = append(result.Changes, AddressChange{
+			Field:        field.name,
+			Input:        field.input,
+			Standardized: field.standardized,
+		})
+	}
+
+	return result
+}
 
-	return resp, err
+func normalize(s string) string {
+	return strings.Join(strings.Fields(strings.ToUpper(s)), " ")
 }
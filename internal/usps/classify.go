@@ -0,0 +1,168 @@
+package usps
+
+import (
+	"math"
+	"slices"
+)
+
+// Rate indicators for parcels accepted by the Domestic Prices v3 API.
+const (
+	RateIndicatorSinglePiece            = "SP"
+	RateIndicatorCubicParcel            = "CP"
+	RateIndicatorDimensionalRectangular = "DR"
+	RateIndicatorOversized              = "OS"
+)
+
+// USPS parcel size thresholds. Dimensions are in inches, volumes in cubic
+// inches and weights in ounces.
+const (
+	// MaxLengthPlusGirth is the largest parcel USPS Ground Advantage accepts.
+	// Every other mail class stops at OversizedLengthPlusGirth.
+	MaxLengthPlusGirth = 130
+	// OversizedLengthPlusGirth is the size above which oversized prices
+	// apply.
+	OversizedLengthPlusGirth = 108
+
+	// DimWeightVolume is the volume above which dimensional weight is
+	// charged when it exceeds the actual weight.
+	DimWeightVolume = 1728
+	// DimWeightDivisor converts cubic inches to pounds of dimensional weight.
+	DimWeightDivisor = 166
+
+	// NonstandardLength is the longest side above which a nonstandard fee
+	// applies, and NonstandardVolume the volume above which one applies.
+	NonstandardLength = 22
+	NonstandardVolume = 2 * 1728
+
+	// Machinable parcels are at most 22 x 18 x 15 inches and 25 pounds, and
+	// at least 6 x 3 x 0.25 inches and 6 ounces.
+	machinableMaxLength = 22
+	machinableMaxWidth  = 18
+	machinableMaxHeight = 15
+	machinableMaxWeight = 25 * 16
+	machinableMinLength = 6
+	machinableMinWidth  = 3
+	machinableMinHeight = 0.25
+	machinableMinWeight = 6
+
+	// Cubic pricing covers parcels of at most half a cubic foot, 20 pounds
+	// and 18 inches on any side, priced in tenth-of-a-cubic-foot tiers.
+	cubicMaxVolume = 864
+	cubicMaxWeight = 20 * 16
+	cubicMaxLength = 18
+)
+
+// Classification describes a parcel the way USPS prices it. Length is the
+// longest side and Girth is measured around the other two.
+type Classification struct {
+	Length             float64  `json:"length"`
+	Width              float64  `json:"width"`
+	Height             float64  `json:"height"`
+	Girth              float64  `json:"girth"`
+	LengthPlusGirth    float64  `json:"lengthPlusGirth"`
+	Volume             float64  `json:"volume"`
+	Weight             float64  `json:"weight"`
+	DimWeight          float64  `json:"dimWeight,omitempty"`
+	BillableWeight     float64  `json:"billableWeight"`
+	CubicTier          float64  `json:"cubicTier,omitempty"`
+	ProcessingCategory string   `json:"processingCategory"`
+	Oversized          bool     `json:"oversized"`
+	Nonstandard        bool     `json:"nonstandard"`
+	NonstandardReasons []string `json:"nonstandardReasons,omitempty"`
+}
+
+// Classify measures a parcel of the given weight (ounces) and dimensions
+// (inches, in any order).
+func Classify(weight, length, width, height float64) Classification {
+	dims := []float64{length, width, height}
+	slices.Sort(dims)
+
+	c := Classification{
+		Length: dims[2],
+		Width:  dims[1],
+		Height: dims[0],
+		Weight: weight,
+	}
+
+	c.Girth = 2 * (c.Width + c.Height)
+	c.LengthPlusGirth = c.Length + c.Girth
+	c.Volume = c.Length * c.Width * c.Height
+
+	c.BillableWeight = weight
+	if c.Volume > DimWeightVolume {
+		// Dimensional weight is rounded up to the next whole pound.
+		c.DimWeight = math.Ceil(c.Volume/DimWeightDivisor) * 16
+		c.BillableWeight = max(weight, c.DimWeight)
+	}
+
+	if c.Volume <= cubicMaxVolume && weight <= cubicMaxWeight && c.Length <= cubicMaxLength {
+		// Tiers are measured on dimensions rounded down to the quarter inch.
+		quarter := func(n float64) float64 { return math.Floor(n*4) / 4 }
+		cubicFeet := quarter(c.Length) * quarter(c.Width) * quarter(c.Height) / 1728
+		c.CubicTier = max(math.Ceil(cubicFeet*10)/10, 0.1)
+	}
+
+	c.Oversized = c.LengthPlusGirth > OversizedLengthPlusGirth
+
+	if c.Length > NonstandardLength {
+		c.NonstandardReasons = append(c.NonstandardReasons, "length over 22 inches")
+	}
+	if c.Volume > NonstandardVolume {
+		c.NonstandardReasons = append(c.NonstandardReasons, "volume over 2 cubic feet")
+	}
+	c.Nonstandard = len(c.NonstandardReasons) > 0
+
+	c.ProcessingCategory = ProcessingCategoryNonstandard
+	if c.machinable() {
+		c.ProcessingCategory = ProcessingCategoryMachinable
+	}
+
+	return c
+}
+
+func (c Classification) machinable() bool {
+	return c.Length <= machinableMaxLength &&
+		c.Width <= machinableMaxWidth &&
+		c.Height <= machinableMaxHeight &&
+		c.Weight <= machinableMaxWeight &&
+		c.Length >= machinableMinLength &&
+		c.Width >= machinableMinWidth &&
+		c.Height >= machinableMinHeight &&
+		c.Weight >= machinableMinWeight
+}
+
+// RateIndicator returns the rate indicator for pricing the parcel as
+// mailClass. Cubic prices are only used when cubic is set, since they need
+// an eligible account. Media and Library Mail are always single-piece.
+func (c Classification) RateIndicator(mailClass string, cubic bool) string {
+	switch mailClass {
+	case MailClassMediaMail, MailClassLibraryMail:
+		return RateIndicatorSinglePiece
+	}
+
+	switch {
+	case c.Oversized:
+		return RateIndicatorOversized
+	case c.DimWeight > 0:
+		return RateIndicatorDimensionalRectangular
+	case cubic && c.CubicTier > 0 && mailClass != MailClassPriorityMailExpress:
+		return RateIndicatorCubicParcel
+	default:
+		return RateIndicatorSinglePiece
+	}
+}
+
+// Classify classifies the parcel in req and fills in its rate indicator,
+// nonstandard flag and, when left empty, its processing category.
+func (req *DomesticBaseRatesRequest) Classify() Classification {
+	c := Classify(req.Weight, req.Length, req.Width, req.Height)
+
+	if req.ProcessingCategory == "" {
+		req.ProcessingCategory = c.ProcessingCategory
+	}
+
+	req.RateIndicator = c.RateIndicator(req.MailClass, req.CubicPricing)
+	req.HasNonstandardCharacteristics = c.Nonstandard
+
+	return c
+}
@@ -0,0 +1,190 @@
+package usps_test
+
+import (
+	"testing"
+
+	"github.com/pistolricks/ShippingApi/internal/usps"
+)
+
+func TestClassifyLengthPlusGirth(t *testing.T) {
+	tests := []struct {
+		name                  string
+		length, width, height float64
+		wantLengthPlusGirth   float64
+		wantOversized         bool
+	}{
+		{name: "At oversized limit", length: 40, width: 17, height: 17, wantLengthPlusGirth: 108},
+		{name: "Over oversized limit", length: 40.5, width: 17, height: 17, wantLengthPlusGirth: 108.5, wantOversized: true},
+		{name: "Dimensions in any order", length: 17, width: 40, height: 17, wantLengthPlusGirth: 108},
+		{name: "At Ground Advantage limit", length: 62, width: 17, height: 17, wantLengthPlusGirth: 130, wantOversized: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := usps.Classify(16, tt.length, tt.width, tt.height)
+
+			if c.LengthPlusGirth != tt.wantLengthPlusGirth {
+				t.Errorf("got length plus girth %v; want %v", c.LengthPlusGirth, tt.wantLengthPlusGirth)
+			}
+			if c.Oversized != tt.wantOversized {
+				t.Errorf("got oversized %t; want %t", c.Oversized, tt.wantOversized)
+			}
+		})
+	}
+}
+
+func TestClassifyDimWeight(t *testing.T) {
+	tests := []struct {
+		name                  string
+		weight                float64
+		length, width, height float64
+		wantDimWeight         float64
+		wantBillableWeight    float64
+	}{
+		{name: "At one cubic foot", weight: 16, length: 12, width: 12, height: 12, wantDimWeight: 0, wantBillableWeight: 16},
+		// 12 x 12 x 12.5 = 1800 cubic inches; 1800 / 166 rounds up to 11 lbs.
+		{name: "Over one cubic foot", weight: 16, length: 12, width: 12, height: 12.5, wantDimWeight: 176, wantBillableWeight: 176},
+		{name: "Actual weight heavier", weight: 200, length: 12, width: 12, height: 12.5, wantDimWeight: 176, wantBillableWeight: 200},
+		// 12 x 12 x 13.834 is just over 1992 = 12 * 166 cubic inches.
+		{name: "Just over a whole pound", weight: 16, length: 12, width: 12, height: 13.84, wantDimWeight: 208, wantBillableWeight: 208},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := usps.Classify(tt.weight, tt.length, tt.width, tt.height)
+
+			if c.DimWeight != tt.wantDimWeight {
+				t.Errorf("got dim weight %v; want %v", c.DimWeight, tt.wantDimWeight)
+			}
+			if c.BillableWeight != tt.wantBillableWeight {
+				t.Errorf("got billable weight %v; want %v", c.BillableWeight, tt.wantBillableWeight)
+			}
+		})
+	}
+}
+
+func TestClassifyCubicTier(t *testing.T) {
+	tests := []struct {
+		name                  string
+		weight                float64
+		length, width, height float64
+		wantTier              float64
+	}{
+		{name: "Smallest tier", weight: 16, length: 6, width: 4, height: 2, wantTier: 0.1},
+		{name: "Top of first tier", weight: 16, length: 12, width: 9.5, height: 1.5, wantTier: 0.1},
+		{name: "Bottom of second tier", weight: 16, length: 12, width: 9.75, height: 1.5, wantTier: 0.2},
+		{name: "Rounded down to the quarter inch", weight: 16, length: 12, width: 9.74, height: 1.5, wantTier: 0.1},
+		{name: "At half a cubic foot", weight: 16, length: 12, width: 12, height: 6, wantTier: 0.5},
+		{name: "Over half a cubic foot", weight: 16, length: 12, width: 12, height: 6.01, wantTier: 0},
+		{name: "At 18 inches", weight: 16, length: 18, width: 8, height: 6, wantTier: 0.5},
+		{name: "Over 18 inches", weight: 16, length: 18.01, width: 4, height: 4, wantTier: 0},
+		{name: "At 20 pounds", weight: 320, length: 12, width: 12, height: 6, wantTier: 0.5},
+		{name: "Over 20 pounds", weight: 320.01, length: 12, width: 12, height: 6, wantTier: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := usps.Classify(tt.weight, tt.length, tt.width, tt.height)
+
+			if c.CubicTier != tt.wantTier {
+				t.Errorf("got cubic tier %v; want %v", c.CubicTier, tt.wantTier)
+			}
+		})
+	}
+}
+
+func TestClassifyProcessingCategory(t *testing.T) {
+	tests := []struct {
+		name                  string
+		weight                float64
+		length, width, height float64
+		want                  string
+	}{
+		{name: "Largest machinable", weight: 400, length: 22, width: 18, height: 15, want: usps.ProcessingCategoryMachinable},
+		{name: "Smallest machinable", weight: 6, length: 6, width: 3, height: 0.25, want: usps.ProcessingCategoryMachinable},
+		{name: "Too long", weight: 16, length: 22.01, width: 10, height: 6, want: usps.ProcessingCategoryNonstandard},
+		{name: "Too wide", weight: 16, length: 20, width: 18.01, height: 6, want: usps.ProcessingCategoryNonstandard},
+		{name: "Too high", weight: 16, length: 20, width: 18, height: 15.01, want: usps.ProcessingCategoryNonstandard},
+		{name: "Too heavy", weight: 400.01, length: 12, width: 10, height: 6, want: usps.ProcessingCategoryNonstandard},
+		{name: "Too short", weight: 16, length: 5.99, width: 4, height: 2, want: usps.ProcessingCategoryNonstandard},
+		{name: "Too narrow", weight: 16, length: 10, width: 2.99, height: 2, want: usps.ProcessingCategoryNonstandard},
+		{name: "Too thin", weight: 16, length: 10, width: 4, height: 0.24, want: usps.ProcessingCategoryNonstandard},
+		{name: "Too light", weight: 5.99, length: 10, width: 6, height: 4, want: usps.ProcessingCategoryNonstandard},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := usps.Classify(tt.weight, tt.length, tt.width, tt.height)
+
+			if c.ProcessingCategory != tt.want {
+				t.Errorf("got processing category %q; want %q", c.ProcessingCategory, tt.want)
+			}
+		})
+	}
+}
+
+func TestClassifyNonstandard(t *testing.T) {
+	tests := []struct {
+		name                  string
+		length, width, height float64
+		wantReasons           int
+	}{
+		{name: "At 22 inches", length: 22, width: 10, height: 6},
+		{name: "Over 22 inches", length: 22.01, width: 10, height: 6, wantReasons: 1},
+		{name: "At 2 cubic feet", length: 16, width: 16, height: 13.5},
+		{name: "Over 2 cubic feet", length: 16, width: 16, height: 13.51, wantReasons: 1},
+		{name: "Long and large", length: 24, width: 16, height: 16, wantReasons: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := usps.Classify(16, tt.length, tt.width, tt.height)
+
+			if len(c.NonstandardReasons) != tt.wantReasons {
+				t.Errorf("got reasons %v; want %d", c.NonstandardReasons, tt.wantReasons)
+			}
+			if c.Nonstandard != (tt.wantReasons > 0) {
+				t.Errorf("got nonstandard %t with reasons %v", c.Nonstandard, c.NonstandardReasons)
+			}
+		})
+	}
+}
+
+func TestRateIndicator(t *testing.T) {
+	var (
+		small      = usps.Classify(16, 10, 6, 4)
+		dimWeight  = usps.Classify(16, 12, 12, 12.5)
+		oversized  = usps.Classify(16, 40.5, 17, 17)
+		notCubic   = usps.Classify(16, 12, 12, 6.01)
+		atOneCubic = usps.Classify(16, 12, 12, 12)
+	)
+
+	tests := []struct {
+		name      string
+		c         usps.Classification
+		mailClass string
+		cubic     bool
+		want      string
+	}{
+		{name: "Single piece", c: small, mailClass: usps.MailClassGroundAdvantage, want: usps.RateIndicatorSinglePiece},
+		{name: "Cubic", c: small, mailClass: usps.MailClassGroundAdvantage, cubic: true, want: usps.RateIndicatorCubicParcel},
+		{name: "Cubic Priority Mail", c: small, mailClass: usps.MailClassPriorityMail, cubic: true, want: usps.RateIndicatorCubicParcel},
+		{name: "No cubic Priority Mail Express", c: small, mailClass: usps.MailClassPriorityMailExpress, cubic: true, want: usps.RateIndicatorSinglePiece},
+		{name: "Over cubic limit", c: notCubic, mailClass: usps.MailClassGroundAdvantage, cubic: true, want: usps.RateIndicatorSinglePiece},
+		{name: "At dimensional weight limit", c: atOneCubic, mailClass: usps.MailClassPriorityMail, want: usps.RateIndicatorSinglePiece},
+		{name: "Dimensional weight", c: dimWeight, mailClass: usps.MailClassPriorityMail, cubic: true, want: usps.RateIndicatorDimensionalRectangular},
+		{name: "Oversized", c: oversized, mailClass: usps.MailClassGroundAdvantage, cubic: true, want: usps.RateIndicatorOversized},
+		{name: "Media Mail oversized", c: oversized, mailClass: usps.MailClassMediaMail, want: usps.RateIndicatorSinglePiece},
+		{name: "Library Mail dimensional", c: dimWeight, mailClass: usps.MailClassLibraryMail, want: usps.RateIndicatorSinglePiece},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.c.RateIndicator(tt.mailClass, tt.cubic)
+
+			if got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
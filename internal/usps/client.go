@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -15,23 +16,47 @@ import (
 // PricesBaseURL is the USPS Prices API base URL
 const PricesBaseURL = "https://apis.usps.com/prices/v3"
 
+// InternationalPricesBaseURL is the USPS International Prices API base URL
+const InternationalPricesBaseURL = "https://apis.usps.com/international-prices/v3"
+
+// Paths of the USPS APIs below the API host. Pointing the clients at another
+// host, such as a uspsfake server, means appending these to its URL.
+const (
+	OAuthPath               = "/oauth2/v3"
+	AddressesPath           = "/addresses/v3"
+	PricesPath              = "/prices/v3"
+	InternationalPricesPath = "/international-prices/v3"
+)
+
+// NewAddressClient creates an Addresses API client that authenticates with
+// the given token provider. An empty baseURL keeps the production API.
+func NewAddressClient(tokenProvider uspssdk.TokenProvider, baseURL string) *uspssdk.Client {
+	var opts []uspssdk.Option
+	if baseURL != "" {
+		opts = append(opts, uspssdk.WithBaseURL(baseURL))
+	}
+	return uspssdk.NewClient(tokenProvider, opts...)
+}
+
 // PricesClient is a minimal client for interacting with the USPS Prices API.
-// It uses the OAuth provider from github.com/my-eq/go-usps to obtain tokens.
+// It shares the TokenProvider interface from github.com/my-eq/go-usps so a
+// single TokenSource can serve both the prices and addresses clients.
 type PricesClient struct {
 	baseURL       string
+	intlBaseURL   string
 	httpClient    *http.Client
-	tokenProvider *uspssdk.OAuthTokenProvider
+	tokenProvider uspssdk.TokenProvider
 }
 
-// NewPricesClient creates a new PricesClient using OAuth credentials.
-// By default it points to the production Prices API base URL.
-func NewPricesClient(clientID, clientSecret string, opts ...uspssdk.OAuthTokenOption) *PricesClient {
-	// NOTE: We alias the import as usspsdk above but refer correctly here.
-	// Fix alias typo by re-declaring opts type below.
+// NewPricesClient creates a new PricesClient that authenticates with the
+// given token provider. By default it points to the production Prices API
+// base URL.
+func NewPricesClient(tokenProvider uspssdk.TokenProvider) *PricesClient {
 	return &PricesClient{
 		baseURL:       PricesBaseURL,
+		intlBaseURL:   InternationalPricesBaseURL,
 		httpClient:    &http.Client{Timeout: 30 * time.Second},
-		tokenProvider: uspssdk.NewOAuthTokenProvider(clientID, clientSecret, opts...),
+		tokenProvider: tokenProvider,
 	}
 }
 
@@ -51,6 +76,14 @@ func (c *PricesClient) WithBaseURL(baseURL string) *PricesClient {
 	return c
 }
 
+// WithInternationalBaseURL overrides the International Prices API base URL.
+func (c *PricesClient) WithInternationalBaseURL(baseURL string) *PricesClient {
+	if baseURL != "" {
+		c.intlBaseURL = baseURL
+	}
+	return c
+}
+
 // DomesticBaseRatesRequest represents the JSON body for the Domestic Prices v3
 // base-rates search endpoint. Field names follow the USPS documentation.
 type DomesticBaseRatesRequest struct {
@@ -66,9 +99,12 @@ type DomesticBaseRatesRequest struct {
 	DestinationEntryFacilityType  string  `json:"destinationEntryFacilityType"`
 	PriceType                     string  `json:"priceType"`
 	MailingDate                   string  `json:"mailingDate"`
-	AccountType                   string  `json:"accountType"`
-	AccountNumber                 string  `json:"accountNumber"`
+	AccountType                   string  `json:"accountType,omitempty"`
+	AccountNumber                 string  `json:"accountNumber,omitempty"`
 	HasNonstandardCharacteristics bool    `json:"hasNonstandardCharacteristics"`
+
+	// CubicPricing lets Classify choose cubic prices for eligible parcels.
+	CubicPricing bool `json:"-"`
 }
 
 // Dims represents package dimensions.
@@ -93,8 +129,6 @@ type BaseRate struct {
 	BaseAmount    float64     `json:"baseAmount,omitempty"`
 	TotalAmount   float64     `json:"totalAmount,omitempty"`
 	Currency      string      `json:"currency,omitempty"`
-	DeliveryDays  string      `json:"deliveryDays,omitempty"`
-	DeliveryDate  string      `json:"deliveryDate,omitempty"`
 	Surcharges    []Surcharge `json:"surcharges,omitempty"`
 	AdditionalRaw interface{} `json:"additionalRaw,omitempty"` // placeholder for unknown fields per product
 }
@@ -104,8 +138,8 @@ type Rate struct {
 	Description string        `json:"description"`
 	PriceType   string        `json:"priceType"`
 	Price       float64       `json:"price"`
-	Weight      int           `json:"weight"`
-	DimWeight   int           `json:"dimWeight"`
+	Weight      float64       `json:"weight"`
+	DimWeight   float64       `json:"dimWeight"`
 	Fees        []interface{} `json:"fees"`
 	StartDate   string        `json:"startDate"`
 	EndDate     string        `json:"endDate"`
@@ -139,12 +173,24 @@ func (c *PricesClient) SearchDomesticBaseRates(ctx context.Context, body Domesti
 		return nil, fmt.Errorf("PricesClient is nil")
 	}
 
-	endpoint := c.baseURL + "/base-rates/search"
+	data, err := c.post(ctx, c.baseURL+"/base-rates/search", body)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &DomesticBaseRatesResponse{Raw: data}
+	// Best-effort typed decode; unknown fields will be ignored
+	_ = json.Unmarshal(data, out)
+	return out, nil
+}
 
+// post sends body as JSON to endpoint with a bearer token and returns the
+// response body of a successful request.
+func (c *PricesClient) post(ctx context.Context, endpoint string, body any) ([]byte, error) {
 	// Acquire token
 	token, err := c.tokenProvider.GetToken(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get OAuth token: %w", err)
+		return nil, classifyTokenError(fmt.Errorf("failed to get OAuth token: %w", err))
 	}
 
 	// Marshal body
@@ -165,26 +211,24 @@ func (c *PricesClient) SearchDomesticBaseRates(ctx context.Context, body Domesti
 	// Execute
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
+		if errors.Is(err, context.Canceled) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: request failed: %w", ErrUpstreamUnavailable, err)
 	}
 	defer resp.Body.Close()
 
-	// Read body bytes so we can both unmarshal and preserve Raw
 	data, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return nil, fmt.Errorf("%w: failed to read response: %w", ErrUpstreamUnavailable, err)
 	}
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		// Try decoding structured error first
 		var errBody map[string]any
 		_ = json.Unmarshal(data, &errBody)
-		return nil, fmt.Errorf("USPS Prices API error: status=%d, body=%v", resp.StatusCode, errBody)
+		return nil, classifyStatus(resp.StatusCode, fmt.Errorf("USPS Prices API error: status=%d, body=%v", resp.StatusCode, errBody))
 	}
 
-	fmt.Println(string(data))
-	out := &DomesticBaseRatesResponse{Raw: data}
-	// Best-effort typed decode; unknown fields will be ignored
-	_ = json.Unmarshal(data, out)
-	return out, nil
+	return data, nil
 }
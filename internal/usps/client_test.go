@@ -0,0 +1,290 @@
+package usps_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	uspssdk "github.com/my-eq/go-usps"
+	"github.com/my-eq/go-usps/models"
+	"github.com/pistolricks/ShippingApi/internal/usps"
+	"github.com/pistolricks/ShippingApi/internal/usps/uspsfake"
+)
+
+// newFake starts a uspsfake server loaded with the development fixtures and
+// returns it along with a token source and the server's URL.
+func newFake(t *testing.T) (*uspsfake.Server, *usps.TokenSource, string) {
+	t.Helper()
+
+	fixtures, err := uspsfake.LoadFixtures("uspsfake/testdata/fixtures.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Tests script their own faults.
+	fixtures.Faults = nil
+
+	fake := uspsfake.New(fixtures)
+
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	tokens := usps.NewTokenSource("id", "secret", 0, uspssdk.WithBaseURL(srv.URL+usps.OAuthPath))
+
+	return fake, tokens, srv.URL
+}
+
+func TestStandardizedAddress(t *testing.T) {
+	fake, tokens, url := newFake(t)
+	client := usps.NewAddressClient(tokens, url+usps.AddressesPath)
+
+	tests := []struct {
+		name    string
+		address models.AddressRequest
+		fault   *uspsfake.Fault
+		wantErr error
+	}{
+		{
+			name:    "Valid",
+			address: models.AddressRequest{StreetAddress: "1600  pennsylvania ave nw", City: "Washington", State: "dc", ZIPCode: "20500"},
+		},
+		{
+			name:    "Not found",
+			address: models.AddressRequest{StreetAddress: "1 Nowhere Rd", State: "NY"},
+			wantErr: usps.ErrAddressNotFound,
+		},
+		{
+			name:    "Invalid ZIP code",
+			address: models.AddressRequest{StreetAddress: "100 Main St", State: "NY", ZIPCode: "99999"},
+			wantErr: usps.ErrInvalidZIPCode,
+		},
+		{
+			name:    "Upstream unavailable",
+			address: models.AddressRequest{StreetAddress: "1 Main St", State: "NY"},
+			fault:   &uspsfake.Fault{Path: usps.AddressesPath, Status: http.StatusServiceUnavailable, Times: 1},
+			wantErr: usps.ErrUpstreamUnavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.fault != nil {
+				fake.AddFault(*tt.fault)
+				t.Cleanup(fake.ClearFaults)
+			}
+
+			resp, err := usps.StandardizedAddress(context.Background(), client, &tt.address)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("got error %v; want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			result := usps.NewAddressResult(&tt.address, resp)
+
+			if result.StreetAddress != "1600 PENNSYLVANIA AVE NW" {
+				t.Errorf("got street address %q", result.StreetAddress)
+			}
+			if len(result.Corrections) != 0 {
+				t.Errorf("got corrections %v; want none", result.Corrections)
+			}
+			if len(result.Changes) == 0 {
+				t.Error("got no changes; want the standardized fields reported")
+			}
+		})
+	}
+}
+
+func TestSearchDomesticBaseRates(t *testing.T) {
+	fake, tokens, url := newFake(t)
+	client := usps.NewPricesClient(tokens).WithBaseURL(url + usps.PricesPath)
+
+	req := usps.DomesticBaseRatesRequest{
+		OriginZIPCode:      "10001",
+		DestinationZIPCode: "94103",
+		Weight:             16,
+		Length:             10,
+		Width:              8,
+		Height:             4,
+		MailClass:          usps.MailClassGroundAdvantage,
+		ProcessingCategory: usps.ProcessingCategoryMachinable,
+		RateIndicator:      usps.RateIndicatorSinglePiece,
+		PriceType:          "COMMERCIAL",
+		MailingDate:        usps.Today(),
+	}
+
+	tests := []struct {
+		name      string
+		mailClass string
+		fault     *uspsfake.Fault
+		wantErr   error
+	}{
+		{name: "Priced", mailClass: usps.MailClassGroundAdvantage},
+		{name: "Rejected", mailClass: usps.MailClassLibraryMail, wantErr: usps.ErrRequestRejected},
+		{
+			name:      "Upstream unavailable",
+			mailClass: usps.MailClassGroundAdvantage,
+			fault:     &uspsfake.Fault{Path: usps.PricesPath, Status: http.StatusInternalServerError, Times: 1},
+			wantErr:   usps.ErrUpstreamUnavailable,
+		},
+		{
+			name:      "Rate limited",
+			mailClass: usps.MailClassGroundAdvantage,
+			fault:     &uspsfake.Fault{Path: usps.PricesPath, Status: http.StatusTooManyRequests, Times: 1},
+			wantErr:   usps.ErrUpstreamUnavailable,
+		},
+		{
+			name:      "Unauthorized",
+			mailClass: usps.MailClassGroundAdvantage,
+			fault:     &uspsfake.Fault{Path: usps.PricesPath, Status: http.StatusUnauthorized, Times: 1},
+			wantErr:   usps.ErrAuthFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.fault != nil {
+				fake.AddFault(*tt.fault)
+				t.Cleanup(fake.ClearFaults)
+			}
+
+			body := req
+			body.MailClass = tt.mailClass
+
+			res, err := client.SearchDomesticBaseRates(context.Background(), body)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("got error %v; want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			quotes := res.Quotes(tt.mailClass)
+			if len(quotes) != 1 || quotes[0].Price <= 0 {
+				t.Fatalf("got quotes %+v; want one priced quote", quotes)
+			}
+		})
+	}
+}
+
+func TestShopDomesticBaseRates(t *testing.T) {
+	_, tokens, url := newFake(t)
+	client := usps.NewPricesClient(tokens).WithBaseURL(url + usps.PricesPath)
+
+	req := usps.DomesticBaseRatesRequest{
+		OriginZIPCode:      "10001",
+		DestinationZIPCode: "94103",
+		Weight:             16,
+		Length:             10,
+		Width:              8,
+		Height:             4,
+		ProcessingCategory: usps.ProcessingCategoryMachinable,
+		PriceType:          "COMMERCIAL",
+		MailingDate:        usps.Today(),
+	}
+
+	res, err := client.ShopDomesticBaseRates(context.Background(), req, usps.MailClassGroundAdvantage, usps.MailClassPriorityMail, usps.MailClassLibraryMail)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res.Quotes) != 2 {
+		t.Errorf("got %d quotes; want 2", len(res.Quotes))
+	}
+	if _, ok := res.Errors[usps.MailClassLibraryMail]; !ok || len(res.Errors) != 1 {
+		t.Errorf("got errors %v; want only %s", res.Errors, usps.MailClassLibraryMail)
+	}
+}
+
+func TestTrack(t *testing.T) {
+	fake, tokens, url := newFake(t)
+	client := usps.NewTrackingClient(tokens).WithBaseURL(url + usps.TrackingPath)
+
+	tests := []struct {
+		name       string
+		number     string
+		fault      *uspsfake.Fault
+		wantStatus string
+		wantErr    error
+	}{
+		{name: "In transit", number: "9400111111111111111111", wantStatus: "In Transit"},
+		{name: "Delivered", number: "9400100000000000000001", wantStatus: "Delivered"},
+		{name: "Not found", number: "0000111111111111111111", wantErr: usps.ErrTrackingNotFound},
+		{
+			name:    "Upstream unavailable",
+			number:  "9400111111111111111111",
+			fault:   &uspsfake.Fault{Path: usps.TrackingPath, Status: http.StatusServiceUnavailable, Times: 1},
+			wantErr: usps.ErrUpstreamUnavailable,
+		},
+		{
+			name:    "Unauthorized",
+			number:  "9400111111111111111111",
+			fault:   &uspsfake.Fault{Path: usps.TrackingPath, Status: http.StatusUnauthorized, Times: 1},
+			wantErr: usps.ErrAuthFailed,
+		},
+		{
+			name:    "Rejected",
+			number:  "9400111111111111111111",
+			fault:   &uspsfake.Fault{Path: usps.TrackingPath, Status: http.StatusBadRequest, Times: 1},
+			wantErr: usps.ErrRequestRejected,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.fault != nil {
+				fake.AddFault(*tt.fault)
+				t.Cleanup(fake.ClearFaults)
+			}
+
+			res, err := client.Track(context.Background(), tt.number)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("got error %v; want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if res.StatusCategory != tt.wantStatus {
+				t.Errorf("got status category %q; want %q", res.StatusCategory, tt.wantStatus)
+			}
+			if len(res.Result().Events) == 0 {
+				t.Error("got no tracking events")
+			}
+		})
+	}
+}
+
+func TestTokenSourceCachesToken(t *testing.T) {
+	_, tokens, _ := newFake(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	first, err := tokens.GetToken(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := tokens.GetToken(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first != second {
+		t.Errorf("got tokens %q and %q; want the cached token reused", first, second)
+	}
+}
@@ -0,0 +1,90 @@
+package usps
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	uspssdk "github.com/my-eq/go-usps"
+)
+
+var (
+	ErrAddressNotFound     = errors.New("address not found")
+	ErrAmbiguousAddress    = errors.New("address matches more than one delivery point")
+	ErrInvalidZIPCode      = errors.New("invalid ZIP code")
+	ErrInvalidAddress      = errors.New("invalid address")
+	ErrAuthFailed          = errors.New("USPS authentication failed")
+	ErrUpstreamUnavailable = errors.New("USPS API unavailable")
+	ErrRequestRejected     = errors.New("USPS rejected the request")
+)
+
+// classifyAddressError maps an error from the addresses API onto one of the
+// package's sentinel errors. The original error is kept in the chain so it
+// can still be logged.
+func classifyAddressError(err error) error {
+	if errors.Is(err, context.Canceled) {
+		return err
+	}
+
+	var oauthErr *uspssdk.OAuthError
+	if errors.As(err, &oauthErr) {
+		return classifyTokenError(err)
+	}
+
+	var apiErr *uspssdk.APIError
+	if !errors.As(err, &apiErr) {
+		return fmt.Errorf("%w: %w", ErrUpstreamUnavailable, err)
+	}
+
+	message := ""
+	if apiErr.ErrorMessage.Error != nil {
+		message = strings.ToLower(apiErr.ErrorMessage.Error.Message)
+	}
+
+	switch {
+	case apiErr.StatusCode == http.StatusUnauthorized, apiErr.StatusCode == http.StatusForbidden:
+		return fmt.Errorf("%w: %w", ErrAuthFailed, err)
+	case apiErr.StatusCode == http.StatusTooManyRequests, apiErr.StatusCode >= 500:
+		return fmt.Errorf("%w: %w", ErrUpstreamUnavailable, err)
+	case apiErr.StatusCode == http.StatusNotFound, strings.Contains(message, "not found"):
+		return fmt.Errorf("%w: %w", ErrAddressNotFound, err)
+	case strings.Contains(message, "multiple addresses"):
+		return fmt.Errorf("%w: %w", ErrAmbiguousAddress, err)
+	case strings.Contains(message, "zip"):
+		return fmt.Errorf("%w: %w", ErrInvalidZIPCode, err)
+	default:
+		return fmt.Errorf("%w: %w", ErrInvalidAddress, err)
+	}
+}
+
+// classifyTokenError maps a failure to get an OAuth token onto
+// ErrUpstreamUnavailable when the token endpoint is down, and ErrAuthFailed
+// otherwise.
+func classifyTokenError(err error) error {
+	if errors.Is(err, context.Canceled) {
+		return err
+	}
+
+	var oauthErr *uspssdk.OAuthError
+	if errors.As(err, &oauthErr) && oauthErr.StatusCode < 500 {
+		return fmt.Errorf("%w: %w", ErrAuthFailed, err)
+	}
+
+	return fmt.Errorf("%w: %w", ErrUpstreamUnavailable, err)
+}
+
+// classifyStatus maps the status of a failed prices or tracking API response
+// onto one of the package's sentinel errors, keeping ErrRequestRejected for
+// requests USPS refused.
+func classifyStatus(status int, err error) error {
+	switch {
+	case status == http.StatusUnauthorized, status == http.StatusForbidden:
+		return fmt.Errorf("%w: %w", ErrAuthFailed, err)
+	case status == http.StatusTooManyRequests, status >= 500:
+		return fmt.Errorf("%w: %w", ErrUpstreamUnavailable, err)
+	default:
+		return fmt.Errorf("%w: %w", ErrRequestRejected, err)
+	}
+}
@@ -0,0 +1,167 @@
+package usps
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/pistolricks/ShippingApi/internal/validator"
+)
+
+// Mail classes accepted by the International Prices v3 API.
+const (
+	MailClassFirstClassPackageInternational = "FIRST-CLASS_PACKAGE_INTERNATIONAL_SERVICE"
+	MailClassPriorityMailInternational      = "PRIORITY_MAIL_INTERNATIONAL"
+	MailClassPriorityMailExpressIntl        = "PRIORITY_MAIL_EXPRESS_INTERNATIONAL"
+	MailClassGlobalExpressGuaranteed        = "GLOBAL_EXPRESS_GUARANTEED"
+)
+
+var InternationalMailClasses = []string{
+	MailClassFirstClassPackageInternational,
+	MailClassPriorityMailInternational,
+	MailClassPriorityMailExpressIntl,
+	MailClassGlobalExpressGuaranteed,
+}
+
+// MaxFirstClassInternationalWeight is the heaviest First-Class Package
+// International Service parcel, in ounces (4 lbs).
+const MaxFirstClassInternationalWeight = 4 * 16
+
+// InternationalBaseRatesRequest represents the JSON body for the
+// International Prices v3 base-rates search endpoint.
+type InternationalBaseRatesRequest struct {
+	OriginZIPCode                string  `json:"originZIPCode"`
+	ForeignPostalCode            string  `json:"foreignPostalCode,omitempty"`
+	DestinationCountryCode       string  `json:"destinationCountryCode"`
+	Weight                       float64 `json:"weight"` // ounces
+	Length                       float64 `json:"length"`
+	Width                        float64 `json:"width"`
+	Height                       float64 `json:"height"`
+	MailClass                    string  `json:"mailClass"`
+	ProcessingCategory           string  `json:"processingCategory"`
+	RateIndicator                string  `json:"rateIndicator"`
+	DestinationEntryFacilityType string  `json:"destinationEntryFacilityType"`
+	PriceType                    string  `json:"priceType"`
+	MailingDate                  string  `json:"mailingDate"`
+	AccountType                  string  `json:"accountType,omitempty"`
+	AccountNumber                string  `json:"accountNumber,omitempty"`
+}
+
+// InternationalBaseRatesResponse is the International Prices API response.
+type InternationalBaseRatesResponse struct {
+	TotalBasePrice float64         `json:"totalBasePrice,omitempty"`
+	Rates          []Rate          `json:"rates,omitempty"`
+	Raw            json.RawMessage `json:"-"`
+}
+
+// Quotes converts the response into quotes, using mailClass for any rate
+// that does not name its own.
+func (r *InternationalBaseRatesResponse) Quotes(mailClass string) []Quote {
+	if r == nil {
+		return nil
+	}
+
+	return quotesFromRates(r.Rates, mailClass)
+}
+
+// SearchInternationalBaseRates calls the USPS International Prices API
+// base-rates search endpoint.
+func (c *PricesClient) SearchInternationalBaseRates(ctx context.Context, body InternationalBaseRatesRequest) (*InternationalBaseRatesResponse, error) {
+	if c == nil {
+		return nil, fmt.Errorf("PricesClient is nil")
+	}
+
+	data, err := c.post(ctx, c.intlBaseURL+"/base-rates/search", body)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &InternationalBaseRatesResponse{Raw: data}
+	_ = json.Unmarshal(data, out)
+	return out, nil
+}
+
+// ShopInternationalBaseRates prices the same parcel across several
+// international mail classes concurrently, like ShopDomesticBaseRates. When
+// mailClasses is empty InternationalMailClasses is used.
+func (c *PricesClient) ShopInternationalBaseRates(ctx context.Context, req InternationalBaseRatesRequest, mailClasses ...string) (*ShopResult, error) {
+	if len(mailClasses) == 0 {
+		mailClasses = InternationalMailClasses
+	}
+
+	var (
+		wg     sync.WaitGroup
+		quotes = make([][]Quote, len(mailClasses))
+		errs   = make([]error, len(mailClasses))
+	)
+
+	for i, mailClass := range mailClasses {
+		if mailClass == MailClassFirstClassPackageInternational && req.Weight > MaxFirstClassInternationalWeight {
+			errs[i] = errors.New("weight is over 4 pounds")
+			continue
+		}
+
+		wg.Go(func() {
+			body := req
+			body.MailClass = mailClass
+
+			res, err := c.SearchInternationalBaseRates(ctx, body)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+
+			quotes[i] = res.Quotes(mailClass)
+		})
+	}
+
+	wg.Wait()
+
+	return mergeShopResults(mailClasses, quotes, errs)
+}
+
+func ValidateInternationalBaseRatesRequest(v *validator.Validator, req InternationalBaseRatesRequest) {
+	v.Check(req.OriginZIPCode != "", "originZIPCode", "must be provided")
+	v.Check(validator.Matches(req.OriginZIPCode, validator.ZIPCodeRX), "originZIPCode", "must be a 5 digit ZIP code")
+
+	v.Check(req.DestinationCountryCode != "", "destinationCountryCode", "must be provided")
+	v.Check(validator.IsCountryCode(req.DestinationCountryCode), "destinationCountryCode", "must be an ISO 3166-1 alpha-2 country code")
+	v.Check(IsInternational(req.DestinationCountryCode), "destinationCountryCode", "must not be the United States or a US territory")
+
+	v.Check(len(req.ForeignPostalCode) <= 20, "foreignPostalCode", "must not be more than 20 characters long")
+
+	v.Check(req.Weight > 0, "weight", "must be greater than zero")
+	v.Check(req.Weight <= MaxWeight, "weight", "must not be more than 1120 ounces")
+
+	v.Check(req.Length > 0, "length", "must be greater than zero")
+	v.Check(req.Width > 0, "width", "must be greater than zero")
+	v.Check(req.Height > 0, "height", "must be greater than zero")
+
+	if req.Length > 0 && req.Width > 0 && req.Height > 0 {
+		c := Classify(req.Weight, req.Length, req.Width, req.Height)
+		v.Check(c.LengthPlusGirth <= OversizedLengthPlusGirth, "dimensions", "length plus girth must not be more than 108 inches")
+	}
+
+	v.Check(validator.PermittedValue(req.MailClass, InternationalMailClasses...), "mailClass", "invalid mail class")
+	if req.MailClass == MailClassFirstClassPackageInternational {
+		v.Check(req.Weight <= MaxFirstClassInternationalWeight, "weight", "must not be more than 64 ounces for First-Class Package International Service")
+	}
+
+	validateMailingDate(v, req.MailingDate)
+}
+
+// usTerritories are the ISO 3166 codes of places USPS serves with domestic
+// mail and ZIP codes.
+var usTerritories = []string{"US", "PR", "GU", "VI", "AS", "MP", "UM", "FM", "MH", "PW"}
+
+// IsInternational reports whether the country code needs international
+// mail. Empty codes are treated as domestic.
+func IsInternational(countryCode string) bool {
+	if countryCode == "" {
+		return false
+	}
+	return !validator.PermittedValue(strings.ToUpper(countryCode), usTerritories...)
+}
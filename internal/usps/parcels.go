@@ -0,0 +1,169 @@
+package usps
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"math"
+	"slices"
+	"sync"
+
+	"github.com/pistolricks/ShippingApi/internal/delivery"
+)
+
+// MaxParcels is the most parcels QuoteParcels prices in one call.
+const MaxParcels = 20
+
+// MaxParcelConcurrency is the most parcels QuoteParcels prices at once.
+// Rate shopping fans out again per mail class, so this bounds the requests
+// in flight to USPS for one call.
+const MaxParcelConcurrency = 4
+
+// ParcelQuotes holds the quotes for one parcel of a multi-parcel request.
+// Error is set when the parcel could not be priced at all; Errors records
+// the mail classes that failed while rate shopping.
+type ParcelQuotes struct {
+	Index   int               `json:"index"`
+	Package Classification    `json:"package"`
+	Quotes  []Quote           `json:"rates"`
+	Errors  map[string]string `json:"errors,omitempty"`
+	Error   string            `json:"error,omitempty"`
+}
+
+// MailClassTotal is the price of sending every parcel by one mail class,
+// using the cheapest quote for each parcel.
+type MailClassTotal struct {
+	MailClass string  `json:"mailClass"`
+	Price     float64 `json:"price"`
+	Currency  string  `json:"currency"`
+	Parcels   int     `json:"parcels"`
+
+	Delivery *delivery.Window `json:"delivery,omitempty"`
+}
+
+// ParcelsResult is the outcome of QuoteParcels. Totals only covers mail
+// classes that priced every parcel and is sorted by price.
+type ParcelsResult struct {
+	Parcels []ParcelQuotes   `json:"parcels"`
+	Totals  []MailClassTotal `json:"totals"`
+}
+
+// Failed returns the number of parcels that could not be priced.
+func (r *ParcelsResult) Failed() int {
+	failed := 0
+	for _, parcel := range r.Parcels {
+		if parcel.Error != "" {
+			failed++
+		}
+	}
+	return failed
+}
+
+// QuoteParcels prices each request, MaxParcelConcurrency at a time, for its
+// own mail class or, when shop is set, across mailClasses (ShopMailClasses
+// when empty). Parcels that fail are reported in the result; an error is only
+// returned when every parcel fails.
+func (c *PricesClient) QuoteParcels(ctx context.Context, reqs []DomesticBaseRatesRequest, shop bool, mailClasses ...string) (*ParcelsResult, error) {
+	var (
+		wg      sync.WaitGroup
+		sem     = make(chan struct{}, MaxParcelConcurrency)
+		parcels = make([]ParcelQuotes, len(reqs))
+		errs    = make([]error, len(reqs))
+	)
+
+	for i, req := range reqs {
+		parcels[i].Index = i
+		parcels[i].Package = Classify(req.Weight, req.Length, req.Width, req.Height)
+
+		sem <- struct{}{}
+
+		wg.Go(func() {
+			defer func() { <-sem }()
+
+			if shop {
+				res, err := c.ShopDomesticBaseRates(ctx, req, mailClasses...)
+				if err != nil {
+					errs[i] = err
+					return
+				}
+
+				parcels[i].Quotes = res.Quotes
+				if len(res.Errors) > 0 {
+					parcels[i].Errors = res.Errors
+				}
+				return
+			}
+
+			res, err := c.SearchDomesticBaseRates(ctx, req)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+
+			parcels[i].Quotes = res.Quotes(req.MailClass)
+			if len(parcels[i].Quotes) == 0 {
+				errs[i] = errors.New("no rates returned")
+			}
+		})
+	}
+
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			parcels[i].Error = err.Error()
+		}
+	}
+
+	result := &ParcelsResult{Parcels: parcels, Totals: totalParcels(parcels)}
+
+	if result.Failed() == len(reqs) {
+		return nil, errors.Join(errs...)
+	}
+
+	return result, nil
+}
+
+// totalParcels sums the cheapest quote of each parcel per mail class,
+// skipping mail classes that are missing a quote for any parcel.
+func totalParcels(parcels []ParcelQuotes) []MailClassTotal {
+	totals := make(map[string]*MailClassTotal)
+
+	for _, parcel := range parcels {
+		cheapest := make(map[string]Quote)
+
+		for _, quote := range parcel.Quotes {
+			if best, ok := cheapest[quote.MailClass]; !ok || quote.Price < best.Price {
+				cheapest[quote.MailClass] = quote
+			}
+		}
+
+		for mailClass, quote := range cheapest {
+			total, ok := totals[mailClass]
+			if !ok {
+				total = &MailClassTotal{MailClass: mailClass, Currency: quote.Currency}
+				totals[mailClass] = total
+			}
+
+			total.Price += quote.Price
+			total.Parcels++
+		}
+	}
+
+	out := []MailClassTotal{}
+
+	for _, total := range totals {
+		if total.Parcels != len(parcels) {
+			continue
+		}
+
+		total.Price = math.Round(total.Price*100) / 100
+		out = append(out, *total)
+	}
+
+	slices.SortFunc(out, func(a, b MailClassTotal) int {
+		return cmp.Compare(a.Price, b.Price)
+	})
+
+	return out
+}
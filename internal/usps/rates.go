@@ -0,0 +1,158 @@
+package usps
+
+import (
+	"time"
+
+	"github.com/pistolricks/ShippingApi/internal/delivery"
+	"github.com/pistolricks/ShippingApi/internal/validator"
+)
+
+// Mail classes accepted by the Domestic Prices v3 API.
+const (
+	MailClassGroundAdvantage     = "USPS_GROUND_ADVANTAGE"
+	MailClassPriorityMail        = "PRIORITY_MAIL"
+	MailClassPriorityMailExpress = "PRIORITY_MAIL_EXPRESS"
+	MailClassMediaMail           = "MEDIA_MAIL"
+	MailClassLibraryMail         = "LIBRARY_MAIL"
+)
+
+// Processing categories accepted by the Domestic Prices v3 API.
+const (
+	ProcessingCategoryLetters       = "LETTERS"
+	ProcessingCategoryFlats         = "FLATS"
+	ProcessingCategoryMachinable    = "MACHINABLE"
+	ProcessingCategoryIrregular     = "IRREGULAR"
+	ProcessingCategoryNonMachinable = "NON_MACHINABLE"
+	ProcessingCategoryNonstandard   = "NONSTANDARD"
+)
+
+var (
+	MailClasses = []string{
+		MailClassGroundAdvantage,
+		MailClassPriorityMail,
+		MailClassPriorityMailExpress,
+		MailClassMediaMail,
+		MailClassLibraryMail,
+	}
+
+	ProcessingCategories = []string{
+		ProcessingCategoryLetters,
+		ProcessingCategoryFlats,
+		ProcessingCategoryMachinable,
+		ProcessingCategoryIrregular,
+		ProcessingCategoryNonMachinable,
+		ProcessingCategoryNonstandard,
+	}
+)
+
+// MaxWeight is the heaviest parcel USPS will accept, in ounces (70 lbs).
+const MaxWeight = 70 * 16
+
+// MailingDateLayout is the date format USPS expects for mailingDate.
+const MailingDateLayout = "2006-01-02"
+
+// Today returns the current local date in MailingDateLayout, the default
+// mailingDate for requests that leave it out.
+func Today() string {
+	return time.Now().Format(MailingDateLayout)
+}
+
+// validateMailingDate checks that date is a YYYY-MM-DD date no earlier than
+// Today. Both sides are local calendar dates, so the comparison doesn't
+// depend on the server's UTC offset.
+func validateMailingDate(v *validator.Validator, date string) {
+	_, err := time.Parse(MailingDateLayout, date)
+	if err != nil {
+		v.AddError("mailingDate", "must be a date in YYYY-MM-DD format")
+		return
+	}
+
+	// Dates in MailingDateLayout sort the same as strings.
+	v.Check(date >= Today(), "mailingDate", "must not be in the past")
+}
+
+func ValidateDomesticBaseRatesRequest(v *validator.Validator, req DomesticBaseRatesRequest) {
+	v.Check(req.OriginZIPCode != "", "originZIPCode", "must be provided")
+	v.Check(validator.Matches(req.OriginZIPCode, validator.ZIPCodeRX), "originZIPCode", "must be a 5 digit ZIP code")
+
+	v.Check(req.DestinationZIPCode != "", "destinationZIPCode", "must be provided")
+	v.Check(validator.Matches(req.DestinationZIPCode, validator.ZIPCodeRX), "destinationZIPCode", "must be a 5 digit ZIP code")
+
+	v.Check(req.Weight > 0, "weight", "must be greater than zero")
+	v.Check(req.Weight <= MaxWeight, "weight", "must not be more than 1120 ounces")
+
+	v.Check(req.Length > 0, "length", "must be greater than zero")
+	v.Check(req.Width > 0, "width", "must be greater than zero")
+	v.Check(req.Height > 0, "height", "must be greater than zero")
+
+	if req.Length > 0 && req.Width > 0 && req.Height > 0 {
+		c := Classify(req.Weight, req.Length, req.Width, req.Height)
+
+		v.Check(c.LengthPlusGirth <= MaxLengthPlusGirth, "dimensions", "length plus girth must not be more than 130 inches")
+		if req.MailClass != MailClassGroundAdvantage {
+			v.Check(!c.Oversized, "dimensions", "length plus girth must not be more than 108 inches for this mail class")
+		}
+	}
+
+	v.Check(validator.PermittedValue(req.MailClass, MailClasses...), "mailClass", "invalid mail class")
+	v.Check(validator.PermittedValue(req.ProcessingCategory, ProcessingCategories...), "processingCategory", "invalid processing category")
+
+	validateMailingDate(v, req.MailingDate)
+}
+
+// Quote is a single normalized price returned to API callers.
+type Quote struct {
+	MailClass          string   `json:"mailClass"`
+	Description        string   `json:"description"`
+	SKU                string   `json:"SKU"`
+	ProcessingCategory string   `json:"processingCategory"`
+	RateIndicator      string   `json:"rateIndicator"`
+	Zone               string   `json:"zone,omitempty"`
+	Price              float64  `json:"price"`
+	Currency           string   `json:"currency"`
+	Tags               []string `json:"tags,omitempty"`
+	Warnings           []string `json:"warnings,omitempty"`
+
+	// Delivery is the expected delivery window, set by callers that know
+	// when the parcel ships.
+	Delivery *delivery.Window `json:"delivery,omitempty"`
+}
+
+// Quotes flattens the rates in a base-rates response into Quotes. The mail
+// class of the request is used when USPS omits it from a rate.
+func (r *DomesticBaseRatesResponse) Quotes(mailClass string) []Quote {
+	if r == nil {
+		return nil
+	}
+
+	return quotesFromRates(r.Rates, mailClass)
+}
+
+func quotesFromRates(rates []Rate, mailClass string) []Quote {
+	quotes := make([]Quote, 0, len(rates))
+
+	for _, rate := range rates {
+		quote := Quote{
+			MailClass:          rate.MailClass,
+			Description:        rate.Description,
+			SKU:                rate.SKU,
+			ProcessingCategory: rate.ProcessingCategory,
+			RateIndicator:      rate.RateIndicator,
+			Zone:               rate.Zone,
+			Price:              rate.Price,
+			Currency:           "USD",
+		}
+
+		if quote.MailClass == "" {
+			quote.MailClass = mailClass
+		}
+
+		for _, warning := range rate.Warnings {
+			quote.Warnings = append(quote.Warnings, warning.WarningDescription)
+		}
+
+		quotes = append(quotes, quote)
+	}
+
+	return quotes
+}
@@ -0,0 +1,216 @@
+package usps
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/pistolricks/ShippingApi/internal/delivery"
+)
+
+// Tags applied to quotes by ShopDomesticBaseRates.
+const (
+	TagCheapest  = "cheapest"
+	TagFastest   = "fastest"
+	TagBestValue = "best_value"
+)
+
+// ShopMailClasses are the mail classes compared when rate shopping.
+var ShopMailClasses = []string{
+	MailClassGroundAdvantage,
+	MailClassPriorityMail,
+	MailClassPriorityMailExpress,
+	MailClassMediaMail,
+	MailClassLibraryMail,
+}
+
+// StorefrontMailClasses are the mail classes offered at storefront
+// checkouts. Media Mail and Library Mail are left out: they are restricted
+// to certain contents, which a checkout can't check.
+var StorefrontMailClasses = []string{
+	MailClassGroundAdvantage,
+	MailClassPriorityMail,
+	MailClassPriorityMailExpress,
+}
+
+// serviceStandards are the published service standards of each mail class.
+// MaxDays ranks quotes by speed. Priority Mail Express is delivered every day
+// of the year, the other domestic classes Monday to Saturday and the
+// international classes on business days.
+var serviceStandards = map[string]delivery.Standard{
+	MailClassPriorityMailExpress: {MinDays: 1, MaxDays: 2, Saturday: true, Sunday: true, Holidays: true},
+	MailClassPriorityMail:        {MinDays: 1, MaxDays: 3, Saturday: true},
+	MailClassGroundAdvantage:     {MinDays: 2, MaxDays: 5, Saturday: true},
+	MailClassMediaMail:           {MinDays: 2, MaxDays: 8, Saturday: true},
+	MailClassLibraryMail:         {MinDays: 2, MaxDays: 8, Saturday: true},
+
+	MailClassPriorityMailExpressIntl:   {MinDays: 3, MaxDays: 5},
+	MailClassPriorityMailInternational: {MinDays: 6, MaxDays: 10},
+	MailClassGlobalExpressGuaranteed:   {MinDays: 1, MaxDays: 3},
+}
+
+// mailClassNames are the customer-facing names of each mail class.
+var mailClassNames = map[string]string{
+	MailClassGroundAdvantage:     "USPS Ground Advantage",
+	MailClassPriorityMail:        "Priority Mail",
+	MailClassPriorityMailExpress: "Priority Mail Express",
+	MailClassMediaMail:           "Media Mail",
+	MailClassLibraryMail:         "Library Mail",
+
+	MailClassFirstClassPackageInternational: "First-Class Package International Service",
+	MailClassPriorityMailInternational:      "Priority Mail International",
+	MailClassPriorityMailExpressIntl:        "Priority Mail Express International",
+	MailClassGlobalExpressGuaranteed:        "Global Express Guaranteed",
+}
+
+// MailClassName returns the customer-facing name of mailClass, falling back
+// to the mail class itself when it is unknown.
+func MailClassName(mailClass string) string {
+	if name, ok := mailClassNames[mailClass]; ok {
+		return name
+	}
+	return mailClass
+}
+
+// ServiceStandard returns the service standard of mailClass and whether it
+// is known.
+func ServiceStandard(mailClass string) (delivery.Standard, bool) {
+	standard, ok := serviceStandards[mailClass]
+	return standard, ok
+}
+
+// ShopResult holds the merged quotes from a rate-shopping call. Errors is
+// keyed by mail class and records the classes USPS could not price.
+type ShopResult struct {
+	Quotes []Quote           `json:"rates"`
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// ShopDomesticBaseRates prices the same parcel across several mail classes
+// concurrently. The MailClass of req is ignored and the rate indicator is
+// chosen per mail class; classes that can't carry a parcel of its size are
+// reported as errors without asking USPS. When mailClasses is empty
+// ShopMailClasses is used. An error is only returned when every mail class
+// fails; otherwise the failures are reported in ShopResult.Errors.
+func (c *PricesClient) ShopDomesticBaseRates(ctx context.Context, req DomesticBaseRatesRequest, mailClasses ...string) (*ShopResult, error) {
+	if len(mailClasses) == 0 {
+		mailClasses = ShopMailClasses
+	}
+
+	class := Classify(req.Weight, req.Length, req.Width, req.Height)
+
+	var (
+		wg     sync.WaitGroup
+		quotes = make([][]Quote, len(mailClasses))
+		errs   = make([]error, len(mailClasses))
+	)
+
+	for i, mailClass := range mailClasses {
+		if class.Oversized && mailClass != MailClassGroundAdvantage {
+			errs[i] = fmt.Errorf("length plus girth is over %d inches", OversizedLengthPlusGirth)
+			continue
+		}
+
+		wg.Go(func() {
+			body := req
+			body.MailClass = mailClass
+			body.RateIndicator = class.RateIndicator(mailClass, req.CubicPricing)
+
+			res, err := c.SearchDomesticBaseRates(ctx, body)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+
+			quotes[i] = res.Quotes(mailClass)
+		})
+	}
+
+	wg.Wait()
+
+	return mergeShopResults(mailClasses, quotes, errs)
+}
+
+// mergeShopResults combines the quotes and errors of each mail class into a
+// tagged ShopResult, failing only when no mail class was priced.
+func mergeShopResults(mailClasses []string, quotes [][]Quote, errs []error) (*ShopResult, error) {
+	result := &ShopResult{Errors: make(map[string]string)}
+
+	for i, mailClass := range mailClasses {
+		if errs[i] != nil {
+			result.Errors[mailClass] = errs[i].Error()
+			continue
+		}
+
+		result.Quotes = append(result.Quotes, quotes[i]...)
+	}
+
+	if len(result.Quotes) == 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	tagQuotes(result.Quotes)
+
+	return result, nil
+}
+
+// tagQuotes marks the cheapest quote, the fastest quote (ties broken on
+// price) and the best-value quote. Best value is the quote with the lowest
+// combined score of price relative to the cheapest quote and service days
+// relative to the fastest quote, so both dimensions carry equal weight.
+// Speed is the most delivery days promised by the mail class's service
+// standard; quotes without a standard are never fastest or best value.
+func tagQuotes(quotes []Quote) {
+	cheapest, fastest := 0, 0
+
+	days := make([]int, len(quotes))
+	for i, quote := range quotes {
+		days[i] = serviceStandards[quote.MailClass].MaxDays
+	}
+
+	for i, quote := range quotes {
+		if quote.Price < quotes[cheapest].Price {
+			cheapest = i
+		}
+
+		switch {
+		case days[i] == 0:
+		case days[fastest] == 0, days[i] < days[fastest]:
+			fastest = i
+		case days[i] == days[fastest] && quote.Price < quotes[fastest].Price:
+			fastest = i
+		}
+	}
+
+	quotes[cheapest].Tags = append(quotes[cheapest].Tags, TagCheapest)
+
+	if days[fastest] > 0 {
+		quotes[fastest].Tags = append(quotes[fastest].Tags, TagFastest)
+	}
+
+	minPrice := quotes[cheapest].Price
+	minDays := float64(days[fastest])
+
+	if minPrice <= 0 || minDays <= 0 {
+		return
+	}
+
+	bestValue := -1
+	bestScore := 0.0
+
+	for i, quote := range quotes {
+		if days[i] == 0 {
+			continue
+		}
+
+		score := quote.Price/minPrice + float64(days[i])/minDays
+		if bestValue == -1 || score < bestScore {
+			bestValue, bestScore = i, score
+		}
+	}
+
+	if bestValue != -1 {
+		quotes[bestValue].Tags = append(quotes[bestValue].Tags, TagBestValue)
+	}
+}
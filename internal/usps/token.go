@@ -0,0 +1,192 @@
+package usps
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	uspssdk "github.com/my-eq/go-usps"
+	"github.com/my-eq/go-usps/models"
+)
+
+// DefaultTokenRefreshWindow is how long before expiry a cached token is
+// refreshed in the background.
+const DefaultTokenRefreshWindow = 5 * time.Minute
+
+// maxTokenRetryBackoff caps how long a TokenSource waits between attempts to
+// refresh a token after the token endpoint failed.
+const maxTokenRetryBackoff = time.Minute
+
+// TokenSource is a uspssdk.TokenProvider shared by every USPS client in the
+// process. It caches the OAuth token until it expires, starts a background
+// refresh once the token enters its refresh window, and collapses concurrent
+// fetches into a single request to the token endpoint.
+type TokenSource struct {
+	oauth         *uspssdk.OAuthClient
+	clientID      string
+	clientSecret  string
+	refreshWindow time.Duration
+	logger        *slog.Logger
+
+	mu        sync.Mutex
+	token     string
+	expiry    time.Time
+	refreshAt time.Time
+	failures  int
+	inflight  *tokenCall
+}
+
+type tokenCall struct {
+	done  chan struct{}
+	token string
+	err   error
+}
+
+// NewTokenSource creates a TokenSource for the given credentials. The options
+// configure the underlying OAuth client, for example its base URL.
+func NewTokenSource(clientID, clientSecret string, refreshWindow time.Duration, opts ...uspssdk.Option) *TokenSource {
+	if refreshWindow <= 0 {
+		refreshWindow = DefaultTokenRefreshWindow
+	}
+
+	return &TokenSource{
+		oauth:         uspssdk.NewOAuthClient(opts...),
+		clientID:      clientID,
+		clientSecret:  clientSecret,
+		refreshWindow: refreshWindow,
+	}
+}
+
+// WithLogger sets the logger failed token refreshes are reported to.
+func (s *TokenSource) WithLogger(logger *slog.Logger) *TokenSource {
+	s.logger = logger
+	return s
+}
+
+// GetToken returns the cached token while it is valid. Callers only wait on
+// the token endpoint when there is no valid token at all.
+func (s *TokenSource) GetToken(ctx context.Context) (string, error) {
+	s.mu.Lock()
+
+	now := time.Now()
+
+	if s.token != "" && now.Before(s.expiry) {
+		token := s.token
+
+		if now.After(s.refreshAt) {
+			s.fetchLocked()
+		}
+
+		s.mu.Unlock()
+		return token, nil
+	}
+
+	call := s.fetchLocked()
+	s.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.token, call.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// fetchLocked returns the in-flight token request, starting one if needed.
+// The request runs detached from any caller's context so that one caller
+// giving up does not fail the others waiting on it. s.mu must be held.
+func (s *TokenSource) fetchLocked() *tokenCall {
+	if s.inflight != nil {
+		return s.inflight
+	}
+
+	call := &tokenCall{done: make(chan struct{})}
+	s.inflight = call
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		token, lifetime, err := s.fetch(ctx)
+
+		s.mu.Lock()
+		now := time.Now()
+		if err == nil {
+			// Refresh within the last refreshWindow of the token's life, but
+			// never earlier than halfway through it.
+			s.token = token
+			s.expiry = now.Add(lifetime)
+			s.refreshAt = s.expiry.Add(-min(s.refreshWindow, lifetime/2))
+			s.failures = 0
+		} else {
+			// Keep serving the cached token, but wait before the next
+			// refresh rather than trying again on every request.
+			s.failures++
+			backoff := tokenRetryBackoff(s.failures)
+			s.refreshAt = now.Add(backoff)
+
+			if s.logger != nil {
+				s.logger.Error("USPS token refresh failed", "error", err.Error(), "failures", s.failures, "retry_in", backoff.String())
+			}
+		}
+		s.inflight = nil
+		s.mu.Unlock()
+
+		call.token, call.err = token, err
+		close(call.done)
+	}()
+
+	return call
+}
+
+// tokenRetryBackoff is how long to wait before refreshing again after the
+// given number of consecutive failures: 1s, 2s, 4s, ... capped at
+// maxTokenRetryBackoff.
+func tokenRetryBackoff(failures int) time.Duration {
+	backoff := time.Second
+	for i := 1; i < failures && backoff < maxTokenRetryBackoff; i++ {
+		backoff *= 2
+	}
+
+	return min(backoff, maxTokenRetryBackoff)
+}
+
+// fetch requests a new token and returns it along with its lifetime.
+func (s *TokenSource) fetch(ctx context.Context) (string, time.Duration, error) {
+	result, err := s.oauth.PostToken(ctx, &models.ClientCredentials{
+		GrantType:    "client_credentials",
+		ClientID:     s.clientID,
+		ClientSecret: s.clientSecret,
+	})
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to acquire OAuth token: %w", err)
+	}
+
+	var (
+		token     string
+		expiresIn int
+	)
+
+	switch resp := result.(type) {
+	case *models.ProviderAccessTokenResponse:
+		token, expiresIn = resp.AccessToken, resp.ExpiresIn
+	case *models.ProviderTokensResponse:
+		token, expiresIn = resp.AccessToken, resp.ExpiresIn
+	default:
+		return "", 0, fmt.Errorf("unexpected token response type: %T", result)
+	}
+
+	if token == "" {
+		return "", 0, fmt.Errorf("token endpoint returned an empty access token")
+	}
+
+	// Tokens without a usable lifetime are still handed out, but only cached
+	// briefly so that a fresh one is fetched soon after.
+	if expiresIn <= 0 {
+		return token, time.Minute, nil
+	}
+
+	return token, time.Duration(expiresIn) * time.Second, nil
+}
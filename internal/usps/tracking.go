@@ -0,0 +1,175 @@
+package usps
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	uspssdk "github.com/my-eq/go-usps"
+	"github.com/pistolricks/ShippingApi/internal/tracking"
+)
+
+// TrackingBaseURL is the USPS Tracking API base URL
+const TrackingBaseURL = "https://apis.usps.com/tracking/v3"
+
+// TrackingPath is the path of the Tracking API below the API host.
+const TrackingPath = "/tracking/v3"
+
+// ErrTrackingNotFound is returned when USPS has no record of a tracking
+// number, which is normal for a label that has not been scanned yet.
+var ErrTrackingNotFound = errors.New("USPS has no tracking information for this number")
+
+// TrackingClient is a minimal client for the USPS Tracking API. Like
+// PricesClient it takes the shared TokenProvider.
+type TrackingClient struct {
+	baseURL       string
+	httpClient    *http.Client
+	tokenProvider uspssdk.TokenProvider
+}
+
+// NewTrackingClient creates a TrackingClient pointing to the production
+// Tracking API.
+func NewTrackingClient(tokenProvider uspssdk.TokenProvider) *TrackingClient {
+	return &TrackingClient{
+		baseURL:       TrackingBaseURL,
+		httpClient:    &http.Client{Timeout: 30 * time.Second},
+		tokenProvider: tokenProvider,
+	}
+}
+
+// WithHTTPClient sets a custom HTTP client.
+func (c *TrackingClient) WithHTTPClient(httpClient *http.Client) *TrackingClient {
+	if httpClient != nil {
+		c.httpClient = httpClient
+	}
+	return c
+}
+
+// WithBaseURL overrides the base URL (useful for testing environments).
+func (c *TrackingClient) WithBaseURL(baseURL string) *TrackingClient {
+	if baseURL != "" {
+		c.baseURL = baseURL
+	}
+	return c
+}
+
+// TrackingEvent is a scan in a USPS tracking history. EventTimestamp is in
+// the local time of the scan; GMTTimestamp is the same moment in UTC.
+type TrackingEvent struct {
+	EventType      string `json:"eventType"`
+	EventTimestamp string `json:"eventTimestamp"`
+	GMTTimestamp   string `json:"GMTTimestamp"`
+	EventCountry   string `json:"eventCountry"`
+	EventCity      string `json:"eventCity"`
+	EventState     string `json:"eventState"`
+	EventZIPCode   string `json:"eventZIP"`
+	EventCode      string `json:"eventCode"`
+}
+
+// TrackingResponse is the Tracking API response for one tracking number,
+// with the raw payload kept in Raw.
+type TrackingResponse struct {
+	TrackingNumber            string          `json:"trackingNumber"`
+	StatusCategory            string          `json:"statusCategory"`
+	Status                    string          `json:"status"`
+	StatusSummary             string          `json:"statusSummary"`
+	ExpectedDeliveryTimeStamp string          `json:"expectedDeliveryTimeStamp"`
+	TrackingEvents            []TrackingEvent `json:"trackingEvents"`
+	Raw                       json.RawMessage `json:"-"`
+}
+
+// Track fetches the detailed tracking history of trackingNumber.
+func (c *TrackingClient) Track(ctx context.Context, trackingNumber string) (*TrackingResponse, error) {
+	if c == nil {
+		return nil, fmt.Errorf("TrackingClient is nil")
+	}
+
+	token, err := c.tokenProvider.GetToken(ctx)
+	if err != nil {
+		return nil, classifyTokenError(fmt.Errorf("failed to get OAuth token: %w", err))
+	}
+
+	endpoint := c.baseURL + "/tracking/" + url.PathEscape(trackingNumber) + "?expand=DETAIL"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: request failed: %w", ErrUpstreamUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to read response: %w", ErrUpstreamUnavailable, err)
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrTrackingNotFound
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var errBody map[string]any
+		_ = json.Unmarshal(data, &errBody)
+		return nil, classifyStatus(resp.StatusCode, fmt.Errorf("USPS Tracking API error: status=%d, body=%v", resp.StatusCode, errBody))
+	}
+
+	out := &TrackingResponse{Raw: data}
+	_ = json.Unmarshal(data, out)
+	return out, nil
+}
+
+// Result normalizes the response into a tracking result.
+func (r *TrackingResponse) Result() *tracking.Result {
+	result := &tracking.Result{Events: make([]tracking.Event, 0, len(r.TrackingEvents))}
+
+	for _, event := range r.TrackingEvents {
+		result.Events = append(result.Events, tracking.Event{
+			Status:        tracking.FromUSPS(event.EventCode, event.EventType),
+			Description:   event.EventType,
+			City:          event.EventCity,
+			State:         event.EventState,
+			Zip:           event.EventZIPCode,
+			Country:       strings.ToUpper(event.EventCountry),
+			OccurredAt:    eventTime(event),
+			CarrierStatus: event.EventCode,
+		})
+	}
+
+	tracking.SortNewestFirst(result.Events)
+	result.Status = tracking.Latest(result.Events)
+
+	if t, err := time.Parse(time.RFC3339, r.ExpectedDeliveryTimeStamp); err == nil {
+		result.EstimatedDelivery = &t
+	}
+
+	return result
+}
+
+// eventTime prefers the UTC timestamp and falls back to the local one, read
+// as UTC, when USPS leaves it out.
+func eventTime(event TrackingEvent) time.Time {
+	if t, err := time.Parse(time.RFC3339, event.GMTTimestamp); err == nil {
+		return t.UTC()
+	}
+
+	if t, err := time.Parse("2006-01-02T15:04:05", event.EventTimestamp); err == nil {
+		return t
+	}
+
+	return time.Time{}
+}
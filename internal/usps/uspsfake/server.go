@@ -0,0 +1,469 @@
+// Package uspsfake is a stand-in for the USPS OAuth, Addresses v3, Prices v3,
+// International Prices v3 and Tracking v3 APIs. It answers from scripted
+// fixtures, falls back to deterministic defaults, and can inject failures so
+// that the address, rate and tracking flows can be exercised without USPS
+// credentials.
+package uspsfake
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// AddressFixture scripts the response for address requests whose query
+// parameters match every non-empty field in Match.
+type AddressFixture struct {
+	Match  map[string]string `json:"match"`
+	Status int               `json:"status,omitempty"`
+	Body   json.RawMessage   `json:"body"`
+}
+
+// RateFixture scripts the response for domestic and international
+// base-rates searches whose JSON body matches every non-empty field in
+// Match.
+type RateFixture struct {
+	Match  map[string]string `json:"match"`
+	Status int               `json:"status,omitempty"`
+	Body   json.RawMessage   `json:"body"`
+}
+
+// TrackingFixture scripts the response for a tracking number.
+type TrackingFixture struct {
+	Number string          `json:"number"`
+	Status int             `json:"status,omitempty"`
+	Body   json.RawMessage `json:"body"`
+}
+
+// Fault makes requests whose path starts with Path fail with Status. A
+// Times of zero fails every matching request; otherwise the fault is
+// removed after failing Times requests.
+type Fault struct {
+	Path    string `json:"path"`
+	Status  int    `json:"status"`
+	Message string `json:"message,omitempty"`
+	Times   int    `json:"times,omitempty"`
+}
+
+// Fixtures is the scripted behaviour of a Server. ClientID and ClientSecret,
+// when set, are the only credentials the token endpoint accepts.
+type Fixtures struct {
+	ClientID     string            `json:"clientID,omitempty"`
+	ClientSecret string            `json:"clientSecret,omitempty"`
+	Addresses    []AddressFixture  `json:"addresses,omitempty"`
+	Rates        []RateFixture     `json:"rates,omitempty"`
+	Tracking     []TrackingFixture `json:"tracking,omitempty"`
+	Faults       []Fault           `json:"faults,omitempty"`
+}
+
+// LoadFixtures reads Fixtures from a JSON file.
+func LoadFixtures(path string) (Fixtures, error) {
+	var fixtures Fixtures
+
+	js, err := os.ReadFile(path)
+	if err != nil {
+		return fixtures, err
+	}
+
+	err = json.Unmarshal(js, &fixtures)
+	if err != nil {
+		return fixtures, fmt.Errorf("uspsfake: invalid fixtures file %s: %w", path, err)
+	}
+
+	return fixtures, nil
+}
+
+// Server is an http.Handler serving the fake USPS APIs under the same paths
+// as apis.usps.com, so a client only needs its base URL changed.
+type Server struct {
+	router   *httprouter.Router
+	mu       sync.Mutex
+	fixtures Fixtures
+	tokens   map[string]bool
+	issued   int
+}
+
+func New(fixtures Fixtures) *Server {
+	s := &Server{
+		fixtures: fixtures,
+		tokens:   make(map[string]bool),
+	}
+
+	router := httprouter.New()
+
+	router.HandlerFunc(http.MethodPost, "/oauth2/v3/token", s.handleToken)
+	router.HandlerFunc(http.MethodGet, "/addresses/v3/address", s.requireToken(s.handleAddress))
+	router.HandlerFunc(http.MethodPost, "/prices/v3/base-rates/search", s.requireToken(s.handleBaseRates))
+	router.HandlerFunc(http.MethodPost, "/international-prices/v3/base-rates/search", s.requireToken(s.handleBaseRates))
+	router.HandlerFunc(http.MethodGet, "/tracking/v3/tracking/:trackingNumber", s.requireToken(s.handleTracking))
+
+	router.HandlerFunc(http.MethodPost, "/__fake/faults", s.handleAddFault)
+	router.HandlerFunc(http.MethodDelete, "/__fake/faults", s.handleClearFaults)
+
+	s.router = router
+
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.URL.Path, "/__fake/") {
+		if fault, ok := s.takeFault(r.URL.Path); ok {
+			message := fault.Message
+			if message == "" {
+				message = http.StatusText(fault.Status)
+			}
+
+			writeError(w, fault.Status, message)
+			return
+		}
+	}
+
+	s.router.ServeHTTP(w, r)
+}
+
+// AddFault scripts a new failure.
+func (s *Server) AddFault(fault Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.fixtures.Faults = append(s.fixtures.Faults, fault)
+}
+
+// ClearFaults removes every scripted failure.
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.fixtures.Faults = nil
+}
+
+func (s *Server) takeFault(path string) (Fault, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, fault := range s.fixtures.Faults {
+		if !strings.HasPrefix(path, fault.Path) {
+			continue
+		}
+
+		if fault.Times > 0 {
+			s.fixtures.Faults[i].Times--
+			if s.fixtures.Faults[i].Times == 0 {
+				s.fixtures.Faults = append(s.fixtures.Faults[:i], s.fixtures.Faults[i+1:]...)
+			}
+		}
+
+		return fault, true
+	}
+
+	return Fault{}, false
+}
+
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request")
+		return
+	}
+
+	if r.PostForm.Get("grant_type") != "client_credentials" {
+		writeOAuthError(w, http.StatusBadRequest, "unsupported_grant_type")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.fixtures.ClientID != "" && (r.PostForm.Get("client_id") != s.fixtures.ClientID || r.PostForm.Get("client_secret") != s.fixtures.ClientSecret) {
+		writeOAuthError(w, http.StatusUnauthorized, "invalid_client")
+		return
+	}
+
+	s.issued++
+	token := fmt.Sprintf("fake-usps-token-%d", s.issued)
+	s.tokens[token] = true
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": token,
+		"token_type":   "Bearer",
+		"issued_at":    time.Now().UnixMilli(),
+		"expires_in":   28800,
+		"status":       "approved",
+		"scope":        "addresses prices international-prices tracking",
+	})
+}
+
+func (s *Server) requireToken(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+		s.mu.Lock()
+		ok := s.tokens[token]
+		s.mu.Unlock()
+
+		if !ok {
+			writeError(w, http.StatusUnauthorized, "Unauthorized: invalid or expired access token")
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+func (s *Server) handleAddress(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	s.mu.Lock()
+	fixtures := s.fixtures.Addresses
+	s.mu.Unlock()
+
+	for _, fixture := range fixtures {
+		if matches(fixture.Match, qs.Get) {
+			writeRaw(w, fixture.Status, fixture.Body)
+			return
+		}
+	}
+
+	if qs.Get("streetAddress") == "" || qs.Get("state") == "" {
+		writeError(w, http.StatusBadRequest, "streetAddress and state are required")
+		return
+	}
+
+	zip := qs.Get("ZIPCode")
+	if zip == "" {
+		zip = "00000"
+	}
+
+	city := strings.ToUpper(qs.Get("city"))
+	if city == "" {
+		city = "ANYTOWN"
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"firm": strings.ToUpper(qs.Get("firm")),
+		"address": map[string]any{
+			"streetAddress":    strings.ToUpper(strings.Join(strings.Fields(qs.Get("streetAddress")), " ")),
+			"secondaryAddress": strings.ToUpper(qs.Get("secondaryAddress")),
+			"city":             city,
+			"state":            strings.ToUpper(qs.Get("state")),
+			"ZIPCode":          zip,
+			"ZIPPlus4":         "0001",
+		},
+		"additionalInfo": map[string]any{
+			"deliveryPoint":        "01",
+			"carrierRoute":         "C001",
+			"DPVConfirmation":      "Y",
+			"DPVCMRA":              "N",
+			"business":             "N",
+			"centralDeliveryPoint": "N",
+			"vacant":               "N",
+		},
+		"matches": []map[string]string{{"code": "31", "text": "Single Response - exact match"}},
+	})
+}
+
+// basePrices is the starting price of each mail class before weight.
+var basePrices = map[string]float64{
+	"USPS_GROUND_ADVANTAGE": 5.25,
+	"PRIORITY_MAIL":         9.35,
+	"PRIORITY_MAIL_EXPRESS": 30.45,
+	"MEDIA_MAIL":            4.13,
+	"LIBRARY_MAIL":          3.93,
+
+	"FIRST-CLASS_PACKAGE_INTERNATIONAL_SERVICE": 16.50,
+	"PRIORITY_MAIL_INTERNATIONAL":               48.20,
+	"PRIORITY_MAIL_EXPRESS_INTERNATIONAL":       63.35,
+	"GLOBAL_EXPRESS_GUARANTEED":                 98.10,
+}
+
+func (s *Server) handleBaseRates(w http.ResponseWriter, r *http.Request) {
+	var body map[string]any
+
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "request body must be JSON")
+		return
+	}
+
+	field := func(key string) string {
+		v, ok := body[key]
+		if !ok || v == nil {
+			return ""
+		}
+		return fmt.Sprint(v)
+	}
+
+	s.mu.Lock()
+	fixtures := s.fixtures.Rates
+	s.mu.Unlock()
+
+	for _, fixture := range fixtures {
+		if matches(fixture.Match, field) {
+			writeRaw(w, fixture.Status, fixture.Body)
+			return
+		}
+	}
+
+	mailClass := field("mailClass")
+
+	base, ok := basePrices[mailClass]
+	if !ok {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("mailClass %q is not supported", mailClass))
+		return
+	}
+
+	weight, _ := body["weight"].(float64)
+	if weight <= 0 {
+		writeError(w, http.StatusBadRequest, "weight must be greater than zero")
+		return
+	}
+
+	price := math.Round((base+weight*0.12)*100) / 100
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"totalBasePrice": price,
+		"rates": []map[string]any{{
+			"SKU":                          "DFAKE" + strings.ToUpper(mailClass[:3]),
+			"description":                  strings.ReplaceAll(mailClass, "_", " "),
+			"priceType":                    field("priceType"),
+			"price":                        price,
+			"weight":                       weight,
+			"dimWeight":                    0,
+			"fees":                         []any{},
+			"startDate":                    time.Now().Format("2006-01-02"),
+			"endDate":                      "",
+			"warnings":                     []any{},
+			"mailClass":                    mailClass,
+			"zone":                         "04",
+			"processingCategory":           field("processingCategory"),
+			"destinationEntryFacilityType": field("destinationEntryFacilityType"),
+			"rateIndicator":                field("rateIndicator"),
+		}},
+	})
+}
+
+// handleTracking answers with the fixture for the tracking number, or a
+// history of a package accepted two days ago and now in transit. Numbers
+// starting with 0000 are unknown to the fake, as unscanned labels are to
+// USPS.
+func (s *Server) handleTracking(w http.ResponseWriter, r *http.Request) {
+	number := httprouter.ParamsFromContext(r.Context()).ByName("trackingNumber")
+
+	s.mu.Lock()
+	fixtures := s.fixtures.Tracking
+	s.mu.Unlock()
+
+	for _, fixture := range fixtures {
+		if strings.EqualFold(fixture.Number, number) {
+			writeRaw(w, fixture.Status, fixture.Body)
+			return
+		}
+	}
+
+	if strings.HasPrefix(number, "0000") {
+		writeError(w, http.StatusNotFound, "The tracking number may be incorrect or the status update is not yet available.")
+		return
+	}
+
+	now := time.Now().UTC().Truncate(time.Hour)
+
+	event := func(age time.Duration, code, eventType, city string) map[string]any {
+		at := now.Add(-age)
+		return map[string]any{
+			"eventType":      eventType,
+			"eventTimestamp": at.Format("2006-01-02T15:04:05"),
+			"GMTTimestamp":   at.Format(time.RFC3339),
+			"eventCountry":   "US",
+			"eventCity":      city,
+			"eventState":     "NY",
+			"eventZIP":       "10001",
+			"eventCode":      code,
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"trackingNumber": number,
+		"statusCategory": "In Transit",
+		"status":         "In Transit to Next Facility",
+		"statusSummary":  "Your item is in transit to the next facility.",
+		"trackingEvents": []map[string]any{
+			event(6*time.Hour, "T1", "In Transit to Next Facility", ""),
+			event(24*time.Hour, "10", "Processed through USPS Facility", "NEW YORK"),
+			event(48*time.Hour, "03", "USPS in possession of item", "NEW YORK"),
+			event(60*time.Hour, "GX", "Shipping Label Created, USPS Awaiting Item", "NEW YORK"),
+		},
+	})
+}
+
+func (s *Server) handleAddFault(w http.ResponseWriter, r *http.Request) {
+	var fault Fault
+
+	err := json.NewDecoder(r.Body).Decode(&fault)
+	if err != nil || fault.Path == "" || fault.Status < 400 {
+		writeError(w, http.StatusBadRequest, "fault must have a path and an error status")
+		return
+	}
+
+	s.AddFault(fault)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleClearFaults(w http.ResponseWriter, r *http.Request) {
+	s.ClearFaults()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func matches(match map[string]string, get func(string) string) bool {
+	for key, want := range match {
+		if want != "" && !strings.EqualFold(strings.TrimSpace(get(key)), strings.TrimSpace(want)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	js, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeRaw(w, status, js)
+}
+
+func writeRaw(w http.ResponseWriter, status int, body []byte) {
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// writeError writes an error in the USPS API error format.
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]any{
+		"apiVersion": "v3",
+		"error": map[string]any{
+			"code":    fmt.Sprint(status),
+			"message": message,
+		},
+	})
+}
+
+func writeOAuthError(w http.ResponseWriter, status int, code string) {
+	writeJSON(w, status, map[string]string{
+		"error":             code,
+		"error_description": http.StatusText(status),
+	})
+}
@@ -0,0 +1,203 @@
+// Package woocommerce implements the payloads exchanged with the WooCommerce
+// shipping method plugin, which posts each cart package and adds the
+// returned rates to the checkout.
+package woocommerce
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Destination is the package destination as WooCommerce stores it.
+type Destination struct {
+	Country  string `json:"country"`
+	State    string `json:"state"`
+	Postcode string `json:"postcode"`
+	City     string `json:"city"`
+	Address  string `json:"address"`
+	Address2 string `json:"address_2"`
+}
+
+// ZIPCode returns the 5 digit ZIP code of a US postcode such as
+// "90210-1234".
+func (d Destination) ZIPCode() string {
+	zip, _, _ := strings.Cut(strings.TrimSpace(d.Postcode), "-")
+	return zip
+}
+
+// Item is a cart line in the package. WooCommerce sends product weights and
+// dimensions as strings in the store's configured units, empty when unset.
+type Item struct {
+	ProductID   int64   `json:"product_id"`
+	VariationID int64   `json:"variation_id"`
+	SKU         string  `json:"sku"`
+	Quantity    int     `json:"quantity"`
+	Weight      Decimal `json:"weight"`
+	Length      Decimal `json:"length"`
+	Width       Decimal `json:"width"`
+	Height      Decimal `json:"height"`
+	LineTotal   float64 `json:"line_total"`
+}
+
+// Contents holds the package items. PHP encodes the cart as an object keyed
+// by cart item key, or as an empty array when the cart is empty, so both
+// forms are accepted.
+type Contents []Item
+
+func (c *Contents) UnmarshalJSON(b []byte) error {
+	b = bytes.TrimSpace(b)
+
+	if len(b) > 0 && b[0] == '[' {
+		var items []Item
+		if err := json.Unmarshal(b, &items); err != nil {
+			return err
+		}
+		*c = items
+		return nil
+	}
+
+	var keyed map[string]Item
+	if err := json.Unmarshal(b, &keyed); err != nil {
+		return err
+	}
+
+	*c = make(Contents, 0, len(keyed))
+	for _, item := range keyed {
+		*c = append(*c, item)
+	}
+
+	return nil
+}
+
+// Package is the body posted by the shipping method for one cart package.
+type Package struct {
+	Contents      Contents    `json:"contents"`
+	ContentsCost  float64     `json:"contents_cost"`
+	Destination   Destination `json:"destination"`
+	WeightUnit    string      `json:"weight_unit"`
+	DimensionUnit string      `json:"dimension_unit"`
+}
+
+// ounces converts each WooCommerce weight unit to ounces.
+var ounces = map[string]float64{
+	"oz":  1,
+	"lbs": 16,
+	"g":   0.03527396195,
+	"kg":  35.27396195,
+}
+
+// inches converts each WooCommerce dimension unit to inches.
+var inches = map[string]float64{
+	"in": 1,
+	"yd": 36,
+	"mm": 0.0393700787,
+	"cm": 0.393700787,
+	"m":  39.3700787,
+}
+
+// Ounces returns the total package weight in ounces. Items without a weight
+// count as weightless.
+func (p Package) Ounces() (float64, error) {
+	unit := p.WeightUnit
+	if unit == "" {
+		unit = "lbs"
+	}
+
+	factor, ok := ounces[unit]
+	if !ok {
+		return 0, fmt.Errorf("unsupported weight unit %q", unit)
+	}
+
+	total := 0.0
+
+	for _, item := range p.Contents {
+		weight, err := parseNumber(item.Weight)
+		if err != nil {
+			return 0, fmt.Errorf("item %d has an invalid weight", item.ProductID)
+		}
+		total += weight * float64(item.Quantity)
+	}
+
+	return total * factor, nil
+}
+
+// Dimensions returns the size in inches of a box holding every item, laid
+// flat and stacked on top of each other: the longest length and width of any
+// item, and the height of the whole stack. It returns zeros when no item has
+// all three dimensions set.
+func (p Package) Dimensions() (length, width, height float64, err error) {
+	unit := p.DimensionUnit
+	if unit == "" {
+		unit = "in"
+	}
+
+	factor, ok := inches[unit]
+	if !ok {
+		return 0, 0, 0, fmt.Errorf("unsupported dimension unit %q", unit)
+	}
+
+	for _, item := range p.Contents {
+		l, errL := parseNumber(item.Length)
+		w, errW := parseNumber(item.Width)
+		h, errH := parseNumber(item.Height)
+		if errL != nil || errW != nil || errH != nil {
+			return 0, 0, 0, fmt.Errorf("item %d has invalid dimensions", item.ProductID)
+		}
+
+		if l <= 0 || w <= 0 || h <= 0 {
+			continue
+		}
+
+		// Lay the item on its largest face, so it adds its smallest
+		// dimension to the stack.
+		dims := []float64{l * factor, w * factor, h * factor}
+		slices.Sort(dims)
+
+		length = max(length, dims[2])
+		width = max(width, dims[1])
+		height += dims[0] * float64(max(item.Quantity, 1))
+	}
+
+	return length, width, height, nil
+}
+
+// Decimal is a number WooCommerce may send either as a JSON number or as a
+// string, which is empty when the value is unset.
+type Decimal string
+
+func (d *Decimal) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*d = Decimal(strings.TrimSpace(s))
+		return nil
+	}
+
+	var n json.Number
+	if err := json.Unmarshal(b, &n); err != nil {
+		return err
+	}
+
+	*d = Decimal(n)
+	return nil
+}
+
+func parseNumber(d Decimal) (float64, error) {
+	if d == "" {
+		return 0, nil
+	}
+	return strconv.ParseFloat(string(d), 64)
+}
+
+// Rate is a WooCommerce shipping rate as passed to WC_Shipping_Method's
+// add_rate. Cost is a decimal string in the store currency.
+type Rate struct {
+	ID       string            `json:"id"`
+	Label    string            `json:"label"`
+	Cost     string            `json:"cost"`
+	CalcTax  string            `json:"calc_tax"`
+	MetaData map[string]string `json:"meta_data,omitempty"`
+}
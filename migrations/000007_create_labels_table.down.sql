@@ -0,0 +1 @@
+DROP TABLE IF EXISTS labels;
\ No newline at end of file
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS labels (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    transaction_id text UNIQUE NOT NULL,
+    rate_id text NOT NULL,
+    carrier text NOT NULL,
+    service_level text NOT NULL,
+    tracking_number text NOT NULL,
+    tracking_url text NOT NULL,
+    label_url text NOT NULL,
+    cost numeric(10, 2) NOT NULL,
+    currency text NOT NULL
+);
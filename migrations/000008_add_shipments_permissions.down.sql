@@ -0,0 +1 @@
+DELETE FROM permissions WHERE code IN ('shipments:read', 'shipments:write');
\ No newline at end of file
@@ -0,0 +1,4 @@
+INSERT INTO permissions (code)
+VALUES
+    ('shipments:read'),
+    ('shipments:write');
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS parcels;
+DROP TABLE IF EXISTS shipments;
+DROP TABLE IF EXISTS addresses;
\ No newline at end of file
@@ -0,0 +1,40 @@
+CREATE TABLE IF NOT EXISTS addresses (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    name text NOT NULL,
+    company text NOT NULL DEFAULT '',
+    street1 text NOT NULL,
+    street2 text NOT NULL DEFAULT '',
+    city text NOT NULL,
+    state text NOT NULL DEFAULT '',
+    zip text NOT NULL,
+    country text NOT NULL,
+    phone text NOT NULL DEFAULT '',
+    email text NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS shipments (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    status text NOT NULL DEFAULT 'draft',
+    reference text NOT NULL DEFAULT '',
+    address_from_id bigint NOT NULL REFERENCES addresses,
+    address_to_id bigint NOT NULL REFERENCES addresses,
+    shippo_shipment_id text NOT NULL DEFAULT '',
+    label_id bigint REFERENCES labels ON DELETE SET NULL,
+    version integer NOT NULL DEFAULT 1
+);
+
+CREATE INDEX IF NOT EXISTS shipments_status_idx ON shipments (status);
+
+CREATE TABLE IF NOT EXISTS parcels (
+    id bigserial PRIMARY KEY,
+    shipment_id bigint NOT NULL REFERENCES shipments ON DELETE CASCADE,
+    length numeric(8, 2) NOT NULL,
+    width numeric(8, 2) NOT NULL,
+    height numeric(8, 2) NOT NULL,
+    weight numeric(8, 2) NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS parcels_shipment_id_idx ON parcels (shipment_id);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS address_cache;
\ No newline at end of file
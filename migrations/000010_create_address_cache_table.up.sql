@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS address_cache (
+    key bytea PRIMARY KEY,
+    response jsonb NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    expiry timestamp(0) with time zone NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS address_cache_expiry_idx ON address_cache (expiry);
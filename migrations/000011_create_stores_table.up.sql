@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS stores (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    name text NOT NULL,
+    platform text NOT NULL,
+    origin_zip_code text NOT NULL,
+    key_hash bytea UNIQUE NOT NULL,
+    version integer NOT NULL DEFAULT 1
+);
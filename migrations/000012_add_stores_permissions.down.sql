@@ -0,0 +1 @@
+DELETE FROM permissions WHERE code IN ('stores:read', 'stores:write');
@@ -0,0 +1,4 @@
+INSERT INTO permissions (code)
+VALUES
+    ('stores:read'),
+    ('stores:write');
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS products (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    sku text UNIQUE NOT NULL,
+    name text NOT NULL,
+    weight numeric(8, 2) NOT NULL,
+    length numeric(6, 2) NOT NULL,
+    width numeric(6, 2) NOT NULL,
+    height numeric(6, 2) NOT NULL,
+    version integer NOT NULL DEFAULT 1
+);
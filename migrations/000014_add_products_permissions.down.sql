@@ -0,0 +1 @@
+DELETE FROM permissions WHERE code IN ('products:read', 'products:write');
@@ -0,0 +1 @@
+ALTER TABLE shipments ADD COLUMN IF NOT EXISTS customs jsonb;
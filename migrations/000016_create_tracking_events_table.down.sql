@@ -0,0 +1,3 @@
+ALTER TABLE shipments DROP COLUMN IF EXISTS tracked_at;
+
+DROP TABLE IF EXISTS tracking_events;
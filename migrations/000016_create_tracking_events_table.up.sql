@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS tracking_events (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    shipment_id bigint NOT NULL REFERENCES shipments ON DELETE CASCADE,
+    status text NOT NULL,
+    description text NOT NULL DEFAULT '',
+    city text NOT NULL DEFAULT '',
+    state text NOT NULL DEFAULT '',
+    zip text NOT NULL DEFAULT '',
+    country text NOT NULL DEFAULT '',
+    occurred_at timestamp(0) with time zone NOT NULL,
+    carrier_status text NOT NULL DEFAULT ''
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS tracking_events_event_idx ON tracking_events (shipment_id, occurred_at, carrier_status, description);
+
+ALTER TABLE shipments ADD COLUMN IF NOT EXISTS tracked_at timestamp(0) with time zone;
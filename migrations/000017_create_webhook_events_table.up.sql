@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS webhook_events (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    provider text NOT NULL,
+    event_id text NOT NULL,
+    event_type text NOT NULL,
+    UNIQUE (provider, event_id)
+);
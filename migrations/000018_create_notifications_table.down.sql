@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS notifications;
+
+ALTER TABLE shipments DROP COLUMN IF EXISTS store_id;
+
+ALTER TABLE stores DROP COLUMN IF EXISTS notify_customers;
@@ -0,0 +1,12 @@
+ALTER TABLE stores ADD COLUMN IF NOT EXISTS notify_customers boolean NOT NULL DEFAULT true;
+
+ALTER TABLE shipments ADD COLUMN IF NOT EXISTS store_id bigint REFERENCES stores ON DELETE SET NULL;
+
+CREATE TABLE IF NOT EXISTS notifications (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    shipment_id bigint NOT NULL REFERENCES shipments ON DELETE CASCADE,
+    kind text NOT NULL,
+    recipient text NOT NULL,
+    UNIQUE (shipment_id, kind)
+);
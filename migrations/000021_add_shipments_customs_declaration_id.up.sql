@@ -0,0 +1 @@
+ALTER TABLE shipments ADD COLUMN IF NOT EXISTS shippo_customs_declaration_id text NOT NULL DEFAULT '';
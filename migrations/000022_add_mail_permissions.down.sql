@@ -0,0 +1 @@
+DELETE FROM permissions WHERE code IN ('mail:read', 'mail:write');
@@ -0,0 +1 @@
+ALTER TABLE shipments ADD COLUMN IF NOT EXISTS shippo_transaction_id text NOT NULL DEFAULT '';